@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/xumak-grid/aem-operator/pkg/cmd"
+	"github.com/xumak-grid/aem-operator/pkg/config"
 	"github.com/xumak-grid/aem-operator/version"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -12,12 +13,34 @@ import (
 
 func main() {
 	kubeConfig := flag.String("kubeconfig", "", "path to kubeconfig file, required for out of cluster e.g: ~/.kube/config")
+	configFile := flag.String("config", os.Getenv("OPERATOR_CONFIG"), "path to the operator configuration file, e.g: /etc/aem-operator/config.yaml")
+	namespaces := flag.String("namespaces", "", "comma separated namespaces watched by the operator, all namespaces by default")
+	namespaceSelector := flag.String("namespace-selector", "", "label selector of the namespaces watched by the operator, e.g: tenant=acme")
+	externalDomain := flag.String("external-domain", "", "domain of the hosts of the instances")
+	workers := flag.Int("workers", 0, "number of deployments synced at the same time")
 	flag.Parse()
 
 	logger, _ := getLogger()
-	checkEnvVar(logger)
+	loader := &config.Loader{
+		Path: *configFile,
+		// the flags override the configuration file and the environment when they are set
+		Overrides: func(c *config.Config) {
+			flag.Visit(func(f *flag.Flag) {
+				switch f.Name {
+				case "namespaces":
+					c.Namespaces = config.SplitList(*namespaces)
+				case "namespace-selector":
+					c.NamespaceSelector = *namespaceSelector
+				case "external-domain":
+					c.ExternalDomain = *externalDomain
+				case "workers":
+					c.Workers = *workers
+				}
+			})
+		},
+	}
 	logger.Info("Initializing AEM Operator")
-	err := cmd.RunOperator(*kubeConfig, logger)
+	err := cmd.RunOperator(*kubeConfig, loader, logger)
 	if err != nil {
 		logger.Error("Error running operator", zap.Error(err))
 	}
@@ -28,16 +51,3 @@ func getLogger() (*zap.Logger, error) {
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	return config.Build(zap.Fields(zap.String("operator_version", version.Version)))
 }
-
-// checkEnvVar checks critical environment variables and exits if one is not present
-func checkEnvVar(log *zap.Logger) {
-	if os.Getenv("VAULT_ADDR") == "" {
-		log.Fatal("VAULT_ADDR is not set and is required")
-	}
-	if os.Getenv("VAULT_TOKEN") == "" {
-		log.Fatal("VAULT_TOKEN is not set and is required")
-	}
-	if os.Getenv("GRID_EXTERNAL_DOMAIN") == "" {
-		log.Fatal("GRID_EXTERNAL_DOMAIN is not set and is required")
-	}
-}
@@ -0,0 +1,69 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AEMCacheFlush represents a flush of the dispatcher cache of a deployment,
+// it is processed once and its result is kept in the status.
+type AEMCacheFlush struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CacheFlushSpec   `json:"spec"`
+	Status            CacheFlushStatus `json:"status"`
+}
+
+// CacheFlushSpec represents the content flushed from the dispatchers.
+type CacheFlushSpec struct {
+	// Deployment is the AEMDeployment whose dispatchers are flushed, it must
+	// live in the same namespace.
+	Deployment string `json:"deployment"`
+
+	// Paths are the content paths invalidated e.g. "/content/site/en", the
+	// files invalidated depend on the invalidate rules of the farm.
+	// +optional
+	Paths []string `json:"paths,omitempty"`
+
+	// Purge deletes every cached file, the paths are ignored when set.
+	// +optional
+	Purge bool `json:"purge,omitempty"`
+}
+
+// CacheFlushPhase represents the phase of a cache flush.
+type CacheFlushPhase string
+
+// Cache flush phases
+const (
+	CacheFlushPhaseNone      CacheFlushPhase = ""
+	CacheFlushPhaseCompleted                 = "Completed"
+	CacheFlushPhaseFailed                    = "Failed"
+)
+
+// CacheFlushStatus represents the result of a cache flush.
+type CacheFlushStatus struct {
+	Phase CacheFlushPhase `json:"phase"`
+	// Reason the flush failed before any dispatcher was flushed.
+	Reason         string `json:"reason,omitempty"`
+	CompletionTime string `json:"completionTime,omitempty"`
+	// Dispatchers are the results of each dispatcher of the deployment.
+	Dispatchers []DispatcherFlushStatus `json:"dispatchers,omitempty"`
+}
+
+// DispatcherFlushStatus represents the result of the flush of a dispatcher.
+type DispatcherFlushStatus struct {
+	Name      string `json:"name"`
+	Succeeded bool   `json:"succeeded"`
+	Error     string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AEMCacheFlushList is a list of cache flushes.
+type AEMCacheFlushList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []AEMCacheFlush `json:"items"`
+}
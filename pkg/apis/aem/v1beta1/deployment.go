@@ -1,6 +1,9 @@
 package v1beta1
 
 import (
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -32,6 +35,101 @@ func (d *AEMDeployment) AsOwnerReference() *metav1.OwnerReference {
 type InstanceSpec struct {
 	Type     string `json:"type"`
 	Replicas int    `json:"replicas"`
+
+	// NodeSelector must match the labels of a node for the instances to be
+	// scheduled on it.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations of the instances.
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity scheduling rules of the instances.
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+
+	// TopologySpreadConstraints replaces the default spread of the instances,
+	// publishers and dispatchers are spread across nodes and zones by default.
+	// +optional
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// RunModes are extra AEM run modes appended to the runmode of the
+	// instances e.g. "stage", "nosamplecontent". Authors and publishers only.
+	// +optional
+	RunModes []string `json:"runModes,omitempty"`
+
+	// JVMOpts are appended to the JVM options derived from the instance type.
+	// Authors and publishers only.
+	// +optional
+	JVMOpts string `json:"jvmOpts,omitempty"`
+
+	// Env is the list of extra environment variables of the AEM container,
+	// CQ_RUNMODE, CQ_PORT and CQ_JVM_OPTS are reserved.
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom is the list of sources to populate the environment of the AEM
+	// container.
+	// +optional
+	EnvFrom []v1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Volumes are extra volumes of the instances, mounted in the AEM
+	// container with VolumeMounts.
+	// +optional
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts are extra mounts of the AEM container.
+	// +optional
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Probes tunes the probes of the AEM container, the defaults depend on
+	// the instance type. Authors and publishers only.
+	// +optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+}
+
+// ProbesSpec represents the probes of the AEM container.
+type ProbesSpec struct {
+	// HealthCheckTags are the tags of the AEM health checks executed by the
+	// liveness probe e.g. "shallow,system".
+	//
+	// Default: "shallow"
+	HealthCheckTags string `json:"healthCheckTags,omitempty"`
+
+	// Startup probe, the instance is not checked for readiness or liveness
+	// until the startup probe succeeds.
+	// +optional
+	Startup *ProbeSpec `json:"startup,omitempty"`
+
+	// Readiness probe.
+	// +optional
+	Readiness *ProbeSpec `json:"readiness,omitempty"`
+
+	// Liveness probe, the AEM container is restarted when it fails.
+	// +optional
+	Liveness *ProbeSpec `json:"liveness,omitempty"`
+}
+
+// ProbeSpec represents the tuning of a probe, zero values keep the defaults.
+type ProbeSpec struct {
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int32 `json:"periodSeconds,omitempty"`
+	TimeoutSeconds      int32 `json:"timeoutSeconds,omitempty"`
+	FailureThreshold    int32 `json:"failureThreshold,omitempty"`
+}
+
+// InstanceSpecFor returns the instance specification of the given runmode.
+func (s *AEMDeploymentSpec) InstanceSpecFor(runmode string) *InstanceSpec {
+	switch runmode {
+	case "author":
+		return &s.Authors
+	case "publish":
+		return &s.Publishers
+	case "dispatcher":
+		return &s.Dispatchers
+	}
+	return &InstanceSpec{}
 }
 
 // AEMDeploymentSpec represents the deployment specification.
@@ -91,6 +189,525 @@ type AEMDeploymentSpec struct {
 
 	// Paused is to pause control of the deployment by the operator.
 	Paused bool `json:"paused,omitempty"`
+
+	// ImagePullSecrets are the secrets used to pull the images of the AEM,
+	// dispatcher and sidecar containers from a private registry.
+	// +optional
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// ImagePullPolicy of every container in the deployment.
+	//
+	// Options: "Always", "IfNotPresent", "Never"
+	// Default: "IfNotPresent"
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Source bootstraps the authors and publishers of a new deployment with
+	// the repository data of an existing deployment.
+	// +optional
+	Source *SourceSpec `json:"source,omitempty"`
+
+	// Backup is the backup policy for the repositories of the authors and
+	// publishers.
+	// +optional
+	Backup *BackupSpec `json:"backup,omitempty"`
+
+	// Security is the security profile of the pods of the deployment.
+	// +optional
+	Security *SecuritySpec `json:"security,omitempty"`
+
+	// Dispatcher is the configuration of the dispatcher farm.
+	// +optional
+	Dispatcher *DispatcherSpec `json:"dispatcher,omitempty"`
+
+	// Exposure configures the ingresses of the instances, the values not set
+	// use the defaults of the operator.
+	// +optional
+	Exposure *ExposureSpec `json:"exposure,omitempty"`
+
+	// NetworkPolicy isolates the tiers of the deployment.
+	// +optional
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+}
+
+// NetworkPolicySpec represents the network policies of a deployment: the
+// publishers are only reached by the dispatchers and the author, the
+// dispatchers by the publishers, the author and the ingress controllers, and
+// the operator reaches every port.
+type NetworkPolicySpec struct {
+	// Enabled creates the network policies, they are deleted when disabled.
+	Enabled bool `json:"enabled"`
+
+	// IngressControllers are the peers allowed to reach the public instances
+	// and the site e.g. the pods of the ingress controller or the gateway.
+	//
+	// Default: the pods of the ingress controller namespace of the operator
+	// +optional
+	IngressControllers []networkingv1.NetworkPolicyPeer `json:"ingressControllers,omitempty"`
+}
+
+// ExposureSpec represents how the instances are exposed outside the cluster.
+type ExposureSpec struct {
+	// Mode selects the objects routing the external traffic, ingresses or
+	// Gateway API HTTPRoutes attached to a gateway.
+	//
+	// Options: "Ingress", "GatewayAPI"
+	// Default: the mode of the operator, "Ingress" if not set
+	Mode ExposureMode `json:"mode,omitempty"`
+
+	// Gateway the HTTPRoutes are attached to in the GatewayAPI mode.
+	//
+	// Default: the gateway of the operator
+	// +optional
+	Gateway *GatewayReference `json:"gateway,omitempty"`
+
+	// IngressClass of the ingresses.
+	//
+	// Default: the ingress class of the operator, "contour" if not set
+	IngressClass string `json:"ingressClass,omitempty"`
+
+	// Annotations added to the ingresses, they are merged over the
+	// annotations of the operator and an empty value removes an annotation
+	// of the operator e.g. the force-ssl-redirect annotation.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// HostTemplate is the Go template of the host of each instance, it is
+	// executed with .Instance, .Runmode, .Deployment, .Namespace and .Domain,
+	// the external domain of the operator.
+	//
+	// Default: "{{.Instance}}-{{.Namespace}}.{{.Domain}}"
+	HostTemplate string `json:"hostTemplate,omitempty"`
+
+	// TLSSecretName is the secret with the certificate of the ingresses.
+	//
+	// Default: "<namespace>-public-tls", "<ingress>-tls" with an issuer
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// Issuer requests the certificates of the ingresses from cert-manager.
+	// +optional
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+
+	// Author is the exposure of the authors.
+	//
+	// Default: Public
+	// +optional
+	Author *AuthorExposure `json:"author,omitempty"`
+
+	// Publish is the exposure of the publishers, they are reached through
+	// the dispatchers.
+	//
+	// Default: Internal
+	// +optional
+	Publish *RunmodeExposure `json:"publish,omitempty"`
+
+	// Dispatcher is the exposure of the dispatchers.
+	//
+	// Default: Public
+	// +optional
+	Dispatcher *RunmodeExposure `json:"dispatcher,omitempty"`
+
+	// Site exposes the site hosts with a single ingress in front of every
+	// ready dispatcher.
+	// +optional
+	Site *SiteExposure `json:"site,omitempty"`
+}
+
+// SiteExposure represents the hosts of the site served by the dispatchers.
+type SiteExposure struct {
+	// Hosts of the site e.g. "www.example.com".
+	Hosts []string `json:"hosts"`
+
+	// TLSSecretName is the secret with the certificate of the site hosts.
+	//
+	// Default: the secret of the instances, "<deployment>-site-ingress-tls"
+	// with an issuer
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+}
+
+// ExposureMode represents the objects routing the external traffic to the instances.
+type ExposureMode string
+
+// Exposure modes
+const (
+	ExposureModeIngress    ExposureMode = "Ingress"
+	ExposureModeGatewayAPI ExposureMode = "GatewayAPI"
+)
+
+// GatewayReference references a Gateway API gateway, TLS is terminated by
+// its listeners.
+type GatewayReference struct {
+	Name string `json:"name"`
+
+	// Namespace of the gateway.
+	//
+	// Default: the namespace of the deployment
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName is the listener the routes are attached to e.g. the HTTPS
+	// listener with the certificate of the hosts.
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// ExposurePolicy represents where the instances of a runmode are reachable from.
+type ExposurePolicy string
+
+// Exposure policies
+const (
+	// ExposurePublic exposes each instance with a service and an ingress.
+	ExposurePublic ExposurePolicy = "Public"
+	// ExposureInternal exposes each instance with a service only reachable inside the cluster.
+	ExposureInternal ExposurePolicy = "Internal"
+	// ExposureNone does not expose the instances.
+	ExposureNone ExposurePolicy = "None"
+)
+
+// RunmodeExposure represents the exposure of the instances of a runmode.
+type RunmodeExposure struct {
+	// Policy of the instances.
+	//
+	// Options: "Public", "Internal", "None"
+	Policy ExposurePolicy `json:"policy,omitempty"`
+}
+
+// AuthorExposure represents the exposure of the authors, the source ranges
+// and the authentication apply to their ingresses and require an ingress
+// controller supporting the ingress-nginx annotations.
+type AuthorExposure struct {
+	// Policy of the authors.
+	//
+	// Options: "Public", "Internal", "None"
+	Policy ExposurePolicy `json:"policy,omitempty"`
+
+	// AllowedSourceRanges are the CIDRs allowed to reach the authors e.g.
+	// "203.0.113.0/24", every source is allowed if empty. Like Auth it
+	// requires an nginx ingress class.
+	// +optional
+	AllowedSourceRanges []string `json:"allowedSourceRanges,omitempty"`
+
+	// Auth requires the users to authenticate before reaching the authors.
+	// +optional
+	Auth *IngressAuthSpec `json:"auth,omitempty"`
+}
+
+// IngressAuthSpec represents the authentication done by the ingress
+// controller, only one of the methods can be set.
+type IngressAuthSpec struct {
+	// BasicAuthSecretName is a secret with a htpasswd file in its "auth" key.
+	// +optional
+	BasicAuthSecretName string `json:"basicAuthSecretName,omitempty"`
+
+	// OAuth2Proxy authenticates the users with an oauth2-proxy.
+	// +optional
+	OAuth2Proxy *OAuth2ProxySpec `json:"oauth2Proxy,omitempty"`
+}
+
+// OAuth2ProxySpec represents an oauth2-proxy checking the requests.
+type OAuth2ProxySpec struct {
+	// URL of the oauth2-proxy e.g. "https://auth.example.com", requests are
+	// checked with <url>/oauth2/auth and users sign in at <url>/oauth2/start.
+	URL string `json:"url"`
+}
+
+// IssuerReference references a cert-manager issuer.
+type IssuerReference struct {
+	Name string `json:"name"`
+
+	// Kind of the issuer.
+	//
+	// Options: "Issuer", "ClusterIssuer"
+	// Default: "Issuer"
+	Kind string `json:"kind,omitempty"`
+}
+
+// DispatcherSpec represents the configuration of the dispatchers of a deployment.
+type DispatcherSpec struct {
+	// PairingWeight is the number of extra times the publisher paired with a
+	// dispatcher is listed in its renders, so most requests go to the paired
+	// publisher and the others are only used for load balancing and failover.
+	// Every publisher receives the same load if zero.
+	// +optional
+	PairingWeight int `json:"pairingWeight,omitempty"`
+
+	// ServerName of the Apache virtual host.
+	//
+	// Default: "bedrock.xumak.com"
+	ServerName string `json:"serverName,omitempty"`
+
+	// ServerAliases of the Apache virtual host.
+	//
+	// Default: "localhost"
+	ServerAliases []string `json:"serverAliases,omitempty"`
+
+	// VirtualHosts are the globs compared against the Host header of the
+	// requests to select the farm e.g. "www.company.com" or "intranet.*".
+	//
+	// Default: "*"
+	VirtualHosts []string `json:"virtualHosts,omitempty"`
+
+	// ClientHeaders are the request headers forwarded to the publishers.
+	//
+	// Default: "*"
+	ClientHeaders []string `json:"clientHeaders,omitempty"`
+
+	// Filters define the requests handled by the dispatcher, they replace
+	// the default filters.
+	// +optional
+	Filters []DispatcherFilter `json:"filters,omitempty"`
+
+	// CacheRules define the responses that are cached, they replace the
+	// default rules which cache everything.
+	// +optional
+	CacheRules []DispatcherRule `json:"cacheRules,omitempty"`
+
+	// InvalidateRules define the files invalidated after an activation,
+	// they replace the default rules.
+	// +optional
+	InvalidateRules []DispatcherRule `json:"invalidateRules,omitempty"`
+
+	// AllowedClients restrict the client addresses allowed to flush the
+	// cache, every client is allowed if empty.
+	// +optional
+	AllowedClients []DispatcherRule `json:"allowedClients,omitempty"`
+
+	// ConfigFrom references a complete Apache and dispatcher configuration
+	// tree used instead of the generated configuration, the other settings
+	// of the dispatcher section are ignored when set.
+	// +optional
+	ConfigFrom *DispatcherConfigSource `json:"configFrom,omitempty"`
+
+	// TLS enables HTTPS on the port 443 of the dispatchers, the per-instance
+	// services and ingresses use HTTPS to reach the dispatchers when set and
+	// the backend protocol annotation of the ingress class is known.
+	// It requires the Ingress exposure mode.
+	// +optional
+	TLS *DispatcherTLSSpec `json:"tls,omitempty"`
+
+	// Cache is the volume of the dispatcher cache, by default the cache is
+	// kept in the container filesystem and is lost when the dispatcher restarts.
+	// +optional
+	Cache *DispatcherCacheSpec `json:"cache,omitempty"`
+}
+
+// Dispatcher cache mediums.
+const (
+	DispatcherCacheDisk             = "Disk"
+	DispatcherCacheMemory           = "Memory"
+	DispatcherCachePersistentVolume = "PersistentVolume"
+)
+
+// DispatcherCacheSpec represents the volume of the dispatcher cache.
+type DispatcherCacheSpec struct {
+	// Medium of the cache. "Disk" is an emptyDir on the node disk,
+	// "Memory" a tmpfs counted against the memory of the dispatcher and
+	// "PersistentVolume" the volume claim of the dispatcher, which keeps the
+	// cache when the dispatcher is restarted.
+	//
+	// Options: "Disk", "Memory", "PersistentVolume"
+	// Default: "Disk"
+	Medium string `json:"medium,omitempty"`
+
+	// SizeLimit is the size limit of the emptyDir or the size requested by
+	// the volume claim.
+	//
+	// Default: no limit for an emptyDir, 10Gi for a volume claim
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+
+	// StorageClassName of the volume claim with the "PersistentVolume" medium.
+	//
+	// Default: "gp2"
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+// DispatcherTLSSpec references the certificate of the dispatchers.
+type DispatcherTLSSpec struct {
+	// SecretName is a kubernetes.io/tls Secret with the certificate and the
+	// key of the dispatchers. When Certificate is set it is the Secret
+	// written by cert-manager.
+	//
+	// Default: "<deployment>-dispatcher-tls" when Certificate is set
+	SecretName string `json:"secretName,omitempty"`
+
+	// Certificate requests the certificate from cert-manager.
+	// +optional
+	Certificate *DispatcherCertificateSpec `json:"certificate,omitempty"`
+}
+
+// DispatcherCertificateSpec represents the cert-manager Certificate created
+// for the dispatchers.
+type DispatcherCertificateSpec struct {
+	// IssuerName is the cert-manager issuer signing the certificate.
+	IssuerName string `json:"issuerName"`
+
+	// IssuerKind is the kind of the issuer.
+	//
+	// Options: "Issuer", "ClusterIssuer"
+	// Default: "Issuer"
+	IssuerKind string `json:"issuerKind,omitempty"`
+
+	// DNSNames of the certificate.
+	//
+	// Default: the serverName and serverAliases set in the spec
+	DNSNames []string `json:"dnsNames,omitempty"`
+}
+
+// DispatcherConfigSource references a user provided Apache and dispatcher
+// configuration tree e.g. the conf.d and conf.dispatcher.d directories of the
+// AEM project archetype.
+type DispatcherConfigSource struct {
+	// ConfigMapName is the ConfigMap holding the configuration tree.
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// SecretName is the Secret holding the configuration tree, it is used
+	// instead of a ConfigMap.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// TarballKey is the key holding a tar or tar.gz archive of the tree. If
+	// empty each key is a file of the tree and "__" in the keys separates the
+	// directories e.g. "conf.d__enabled_vhosts__default.vhost".
+	// +optional
+	TarballKey string `json:"tarballKey,omitempty"`
+
+	// MountPath is the server root of Apache in the dispatcher image, the
+	// tree is overlaid on it and relative Apache includes are resolved from it.
+	//
+	// Default: "/usr/local/apache2"
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// Dispatcher rule types.
+const (
+	DispatcherRuleAllow = "allow"
+	DispatcherRuleDeny  = "deny"
+)
+
+// DispatcherFilter represents an entry of the filter section of the farm.
+// The elements of the request line are compared as globs, values enclosed in
+// single quotes are regular expressions e.g. "'(css|js)'".
+type DispatcherFilter struct {
+	// Type of the filter.
+	//
+	// Options: "allow", "deny"
+	Type string `json:"type"`
+
+	// Glob is compared against the entire request line.
+	// +optional
+	Glob string `json:"glob,omitempty"`
+
+	Method    string `json:"method,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Query     string `json:"query,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Selectors string `json:"selectors,omitempty"`
+	Extension string `json:"extension,omitempty"`
+	Suffix    string `json:"suffix,omitempty"`
+}
+
+// DispatcherRule represents a glob rule of the cache, invalidate or
+// allowedClients sections of the farm.
+type DispatcherRule struct {
+	// Glob is the pattern compared against the URL or the client address.
+	Glob string `json:"glob"`
+
+	// Type of the rule.
+	//
+	// Options: "allow", "deny"
+	Type string `json:"type"`
+}
+
+// SecuritySpec represents the security profile of the pods of a deployment.
+type SecuritySpec struct {
+	// Hardened runs every container as a non root user without privilege
+	// escalation, with all capabilities dropped and a seccomp profile, the
+	// dispatcher and sidecar containers get a read only root filesystem.
+	// The dispatcher pods lower the first unprivileged port with a sysctl so
+	// httpd binds the ports 80 and 443 as the non root user.
+	Hardened bool `json:"hardened"`
+
+	// RunAsUser is the user of the containers.
+	//
+	// Default: "1000"
+	RunAsUser *int64 `json:"runAsUser,omitempty"`
+
+	// RunAsGroup is the primary group of the containers.
+	// +optional
+	RunAsGroup *int64 `json:"runAsGroup,omitempty"`
+
+	// FSGroup owns the volumes of the pods so the repository is writable.
+	//
+	// Default: "1000"
+	FSGroup *int64 `json:"fsGroup,omitempty"`
+
+	// SeccompProfile of the pods.
+	//
+	// Default: "runtime/default"
+	SeccompProfile string `json:"seccompProfile,omitempty"`
+}
+
+// Backup modes.
+const (
+	BackupModeSnapshot = "snapshot"
+)
+
+// BackupSpec represents the backup policy of a deployment.
+type BackupSpec struct {
+	// Mode is the way backups are taken, snapshot mode creates a CSI
+	// VolumeSnapshot for the volume of each instance.
+	//
+	// Options: "snapshot"
+	// Default: "snapshot"
+	Mode string `json:"mode,omitempty"`
+
+	// SnapshotClassName is the VolumeSnapshotClass used to take the
+	// snapshots, the cluster default is used if empty.
+	// +optional
+	SnapshotClassName string `json:"snapshotClassName,omitempty"`
+
+	// BackupIntervalInSecond is the time between two backups.
+	BackupIntervalInSecond int64 `json:"backupIntervalInSecond"`
+
+	// MaxBackups is the number of ready backups kept, older backups are deleted.
+	//
+	// Default: "5"
+	MaxBackups int `json:"maxBackups,omitempty"`
+
+	// Quiesce puts every instance in a quiesced state while its snapshot
+	// is taken.
+	// +optional
+	Quiesce *QuiesceSpec `json:"quiesce,omitempty"`
+}
+
+// QuiesceSpec represents the HTTP calls made to an instance around a snapshot
+// e.g. JMX operations exposed by the Felix web console.
+type QuiesceSpec struct {
+	// PrePath is requested with POST before the snapshot is taken.
+	PrePath string `json:"prePath"`
+	// PostPath is requested with POST after the snapshot is taken.
+	PostPath string `json:"postPath"`
+}
+
+// SourceSpec references the deployment whose repository data is copied into
+// a new deployment.
+type SourceSpec struct {
+	// Deployment is the name of the source deployment, it must live in the
+	// same namespace since volumes can only be cloned within a namespace.
+	Deployment string `json:"deployment"`
+
+	// Instance restricts the copy to a single source instance e.g.
+	// "author-001", every new instance with the same runmode is bootstrapped
+	// from it. If empty, each instance is copied from the source instance
+	// with the same runmode and ordinal.
+	// +optional
+	Instance string `json:"instance,omitempty"`
+
+	// BackupID selects a backup of the source instances, the volumes are
+	// restored from the VolumeSnapshot of that backup instead of cloning the
+	// live volumes.
+	// +optional
+	BackupID string `json:"backupID,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -40,6 +40,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&AEMDeployment{},
 		&AEMDeploymentList{},
+		&AEMCacheFlush{},
+		&AEMCacheFlushList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -1,5 +1,7 @@
 package v1beta1
 
+import "time"
+
 // DeploymentPhase represents the current phase in which a deployment may be.
 type DeploymentPhase string
 
@@ -23,6 +25,17 @@ type AEMDeploymentStatus struct {
 	DispatcherVersion string `json:"dispatcherVersion"`
 	// Represents the latest available observations of a deployment's current state.
 	Conditions []DeploymentCondition `json:"conditions,omitempty"`
+	// Backups available for the deployment, the newest first.
+	Backups []BackupStatus `json:"backups,omitempty"`
+}
+
+// BackupStatus represents a backup of the instances of a deployment.
+type BackupStatus struct {
+	ID           string `json:"id"`
+	CreationTime string `json:"creationTime"`
+	// Ready indicates the snapshots of every instance are ready to be used.
+	Ready     bool     `json:"ready"`
+	Instances []string `json:"instances,omitempty"`
 }
 
 // DeploymentConditionType is the type of condition of the deployment.
@@ -44,4 +57,37 @@ const (
 	DeploymentConditionScalingDown        = "ScalingDown"
 	DeploymentConditionGarbageCollecting  = "DataStoreGarbageCollecting"
 	DeploymentConditionUpgrading          = "Upgrading"
+	DeploymentConditionInvalidSpec        = "InvalidSpec"
 )
+
+// SetCondition adds or updates the condition of the given type, returns true if the status changed.
+func (s *AEMDeploymentStatus) SetCondition(conditionType DeploymentConditionType, reason string) bool {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type != conditionType {
+			continue
+		}
+		if s.Conditions[i].Reason == reason {
+			return false
+		}
+		s.Conditions[i].Reason = reason
+		s.Conditions[i].TransitionTime = time.Now().UTC().Format(time.RFC3339)
+		return true
+	}
+	s.Conditions = append(s.Conditions, DeploymentCondition{
+		Type:           conditionType,
+		Reason:         reason,
+		TransitionTime: time.Now().UTC().Format(time.RFC3339),
+	})
+	return true
+}
+
+// RemoveCondition removes the condition of the given type, returns true if the status changed.
+func (s *AEMDeploymentStatus) RemoveCondition(conditionType DeploymentConditionType) bool {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			s.Conditions = append(s.Conditions[:i], s.Conditions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -8,10 +8,73 @@
 package v1beta1
 
 import (
+	core_v1 "k8s.io/api/core/v1"
+	networking_v1 "k8s.io/api/networking/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AEMCacheFlush) DeepCopyInto(out *AEMCacheFlush) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AEMCacheFlush.
+func (in *AEMCacheFlush) DeepCopy() *AEMCacheFlush {
+	if in == nil {
+		return nil
+	}
+	out := new(AEMCacheFlush)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AEMCacheFlush) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AEMCacheFlushList) DeepCopyInto(out *AEMCacheFlushList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AEMCacheFlush, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AEMCacheFlushList.
+func (in *AEMCacheFlushList) DeepCopy() *AEMCacheFlushList {
+	if in == nil {
+		return nil
+	}
+	out := new(AEMCacheFlushList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AEMCacheFlushList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AEMDeployment) DeepCopyInto(out *AEMDeployment) {
 	*out = *in
@@ -85,9 +148,68 @@ func (in *AEMDeploymentSpec) DeepCopyInto(out *AEMDeploymentSpec) {
 			(*in).DeepCopyInto(*out)
 		}
 	}
-	out.Authors = in.Authors
-	out.Publishers = in.Publishers
-	out.Dispatchers = in.Dispatchers
+	in.Authors.DeepCopyInto(&out.Authors)
+	in.Publishers.DeepCopyInto(&out.Publishers)
+	in.Dispatchers.DeepCopyInto(&out.Dispatchers)
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]core_v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SourceSpec)
+			**out = **in
+		}
+	}
+	if in.Backup != nil {
+		in, out := &in.Backup, &out.Backup
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(BackupSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Security != nil {
+		in, out := &in.Security, &out.Security
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SecuritySpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Dispatcher != nil {
+		in, out := &in.Dispatcher, &out.Dispatcher
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(DispatcherSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Exposure != nil {
+		in, out := &in.Exposure, &out.Exposure
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ExposureSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(NetworkPolicySpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
 	return
 }
 
@@ -109,6 +231,13 @@ func (in *AEMDeploymentStatus) DeepCopyInto(out *AEMDeploymentStatus) {
 		*out = make([]DeploymentCondition, len(*in))
 		copy(*out, *in)
 	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]BackupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -122,6 +251,124 @@ func (in *AEMDeploymentStatus) DeepCopy() *AEMDeploymentStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthorExposure) DeepCopyInto(out *AuthorExposure) {
+	*out = *in
+	if in.AllowedSourceRanges != nil {
+		in, out := &in.AllowedSourceRanges, &out.AllowedSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(IngressAuthSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthorExposure.
+func (in *AuthorExposure) DeepCopy() *AuthorExposure {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthorExposure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupSpec) DeepCopyInto(out *BackupSpec) {
+	*out = *in
+	if in.Quiesce != nil {
+		in, out := &in.Quiesce, &out.Quiesce
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(QuiesceSpec)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSpec.
+func (in *BackupSpec) DeepCopy() *BackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupStatus) DeepCopyInto(out *BackupStatus) {
+	*out = *in
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStatus.
+func (in *BackupStatus) DeepCopy() *BackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheFlushSpec) DeepCopyInto(out *CacheFlushSpec) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheFlushSpec.
+func (in *CacheFlushSpec) DeepCopy() *CacheFlushSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheFlushSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheFlushStatus) DeepCopyInto(out *CacheFlushStatus) {
+	*out = *in
+	if in.Dispatchers != nil {
+		in, out := &in.Dispatchers, &out.Dispatchers
+		*out = make([]DispatcherFlushStatus, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheFlushStatus.
+func (in *CacheFlushStatus) DeepCopy() *CacheFlushStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheFlushStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeploymentCondition) DeepCopyInto(out *DeploymentCondition) {
 	*out = *in
@@ -139,17 +386,655 @@ func (in *DeploymentCondition) DeepCopy() *DeploymentCondition {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *InstanceSpec) DeepCopyInto(out *InstanceSpec) {
+func (in *DispatcherCacheSpec) DeepCopyInto(out *DispatcherCacheSpec) {
 	*out = *in
+	if in.SizeLimit != nil {
+		in, out := &in.SizeLimit, &out.SizeLimit
+		if *in == nil {
+			*out = nil
+		} else {
+			x := (*in).DeepCopy()
+			*out = &x
+		}
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(string)
+			**out = **in
+		}
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceSpec.
-func (in *InstanceSpec) DeepCopy() *InstanceSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherCacheSpec.
+func (in *DispatcherCacheSpec) DeepCopy() *DispatcherCacheSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(InstanceSpec)
+	out := new(DispatcherCacheSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DispatcherCertificateSpec) DeepCopyInto(out *DispatcherCertificateSpec) {
+	*out = *in
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherCertificateSpec.
+func (in *DispatcherCertificateSpec) DeepCopy() *DispatcherCertificateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DispatcherCertificateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DispatcherConfigSource) DeepCopyInto(out *DispatcherConfigSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherConfigSource.
+func (in *DispatcherConfigSource) DeepCopy() *DispatcherConfigSource {
+	if in == nil {
+		return nil
+	}
+	out := new(DispatcherConfigSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DispatcherFilter) DeepCopyInto(out *DispatcherFilter) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherFilter.
+func (in *DispatcherFilter) DeepCopy() *DispatcherFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(DispatcherFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DispatcherFlushStatus) DeepCopyInto(out *DispatcherFlushStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherFlushStatus.
+func (in *DispatcherFlushStatus) DeepCopy() *DispatcherFlushStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DispatcherFlushStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DispatcherRule) DeepCopyInto(out *DispatcherRule) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherRule.
+func (in *DispatcherRule) DeepCopy() *DispatcherRule {
+	if in == nil {
+		return nil
+	}
+	out := new(DispatcherRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DispatcherSpec) DeepCopyInto(out *DispatcherSpec) {
+	*out = *in
+	if in.ServerAliases != nil {
+		in, out := &in.ServerAliases, &out.ServerAliases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.VirtualHosts != nil {
+		in, out := &in.VirtualHosts, &out.VirtualHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientHeaders != nil {
+		in, out := &in.ClientHeaders, &out.ClientHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Filters != nil {
+		in, out := &in.Filters, &out.Filters
+		*out = make([]DispatcherFilter, len(*in))
+		copy(*out, *in)
+	}
+	if in.CacheRules != nil {
+		in, out := &in.CacheRules, &out.CacheRules
+		*out = make([]DispatcherRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.InvalidateRules != nil {
+		in, out := &in.InvalidateRules, &out.InvalidateRules
+		*out = make([]DispatcherRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedClients != nil {
+		in, out := &in.AllowedClients, &out.AllowedClients
+		*out = make([]DispatcherRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigFrom != nil {
+		in, out := &in.ConfigFrom, &out.ConfigFrom
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(DispatcherConfigSource)
+			**out = **in
+		}
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(DispatcherTLSSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(DispatcherCacheSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherSpec.
+func (in *DispatcherSpec) DeepCopy() *DispatcherSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DispatcherSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DispatcherTLSSpec) DeepCopyInto(out *DispatcherTLSSpec) {
+	*out = *in
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(DispatcherCertificateSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DispatcherTLSSpec.
+func (in *DispatcherTLSSpec) DeepCopy() *DispatcherTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DispatcherTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExposureSpec) DeepCopyInto(out *ExposureSpec) {
+	*out = *in
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(GatewayReference)
+			**out = **in
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Issuer != nil {
+		in, out := &in.Issuer, &out.Issuer
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(IssuerReference)
+			**out = **in
+		}
+	}
+	if in.Author != nil {
+		in, out := &in.Author, &out.Author
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(AuthorExposure)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.Publish != nil {
+		in, out := &in.Publish, &out.Publish
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(RunmodeExposure)
+			**out = **in
+		}
+	}
+	if in.Dispatcher != nil {
+		in, out := &in.Dispatcher, &out.Dispatcher
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(RunmodeExposure)
+			**out = **in
+		}
+	}
+	if in.Site != nil {
+		in, out := &in.Site, &out.Site
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(SiteExposure)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExposureSpec.
+func (in *ExposureSpec) DeepCopy() *ExposureSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExposureSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayReference) DeepCopyInto(out *GatewayReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayReference.
+func (in *GatewayReference) DeepCopy() *GatewayReference {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressAuthSpec) DeepCopyInto(out *IngressAuthSpec) {
+	*out = *in
+	if in.OAuth2Proxy != nil {
+		in, out := &in.OAuth2Proxy, &out.OAuth2Proxy
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(OAuth2ProxySpec)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressAuthSpec.
+func (in *IngressAuthSpec) DeepCopy() *IngressAuthSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressAuthSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceSpec) DeepCopyInto(out *InstanceSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]core_v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(core_v1.Affinity)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]core_v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RunModes != nil {
+		in, out := &in.RunModes, &out.RunModes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]core_v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EnvFrom != nil {
+		in, out := &in.EnvFrom, &out.EnvFrom
+		*out = make([]core_v1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]core_v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]core_v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ProbesSpec)
+			(*in).DeepCopyInto(*out)
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceSpec.
+func (in *InstanceSpec) DeepCopy() *InstanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IssuerReference) DeepCopyInto(out *IssuerReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssuerReference.
+func (in *IssuerReference) DeepCopy() *IssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(IssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicySpec) DeepCopyInto(out *NetworkPolicySpec) {
+	*out = *in
+	if in.IngressControllers != nil {
+		in, out := &in.IngressControllers, &out.IngressControllers
+		*out = make([]networking_v1.NetworkPolicyPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicySpec.
+func (in *NetworkPolicySpec) DeepCopy() *NetworkPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2ProxySpec) DeepCopyInto(out *OAuth2ProxySpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2ProxySpec.
+func (in *OAuth2ProxySpec) DeepCopy() *OAuth2ProxySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2ProxySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeSpec) DeepCopyInto(out *ProbeSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeSpec.
+func (in *ProbeSpec) DeepCopy() *ProbeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbesSpec) DeepCopyInto(out *ProbesSpec) {
+	*out = *in
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ProbeSpec)
+			**out = **in
+		}
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ProbeSpec)
+			**out = **in
+		}
+	}
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(ProbeSpec)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbesSpec.
+func (in *ProbesSpec) DeepCopy() *ProbesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuiesceSpec) DeepCopyInto(out *QuiesceSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuiesceSpec.
+func (in *QuiesceSpec) DeepCopy() *QuiesceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(QuiesceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunmodeExposure) DeepCopyInto(out *RunmodeExposure) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunmodeExposure.
+func (in *RunmodeExposure) DeepCopy() *RunmodeExposure {
+	if in == nil {
+		return nil
+	}
+	out := new(RunmodeExposure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecuritySpec) DeepCopyInto(out *SecuritySpec) {
+	*out = *in
+	if in.RunAsUser != nil {
+		in, out := &in.RunAsUser, &out.RunAsUser
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
+	if in.RunAsGroup != nil {
+		in, out := &in.RunAsGroup, &out.RunAsGroup
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
+	if in.FSGroup != nil {
+		in, out := &in.FSGroup, &out.FSGroup
+		if *in == nil {
+			*out = nil
+		} else {
+			*out = new(int64)
+			**out = **in
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecuritySpec.
+func (in *SecuritySpec) DeepCopy() *SecuritySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SecuritySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteExposure) DeepCopyInto(out *SiteExposure) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteExposure.
+func (in *SiteExposure) DeepCopy() *SiteExposure {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteExposure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceSpec) DeepCopyInto(out *SourceSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceSpec.
+func (in *SourceSpec) DeepCopy() *SourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -3,20 +3,33 @@ package cmd
 import (
 	"os"
 	"os/signal"
+	"time"
 
+	"github.com/xumak-grid/aem-operator/pkg/config"
 	"github.com/xumak-grid/aem-operator/pkg/operator"
 	"go.uber.org/zap"
 )
 
+// configReloadInterval is the interval the configuration file is checked for changes, mounted
+// ConfigMaps are updated by the kubelet within a minute
+const configReloadInterval = 30 * time.Second
+
 // RunOperator runs the operator controller.
-func RunOperator(cfg string, logger *zap.Logger) error {
+func RunOperator(cfg string, loader *config.Loader, logger *zap.Logger) error {
+	operatorConfig, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	config.Set(operatorConfig)
+
 	signals := make(chan os.Signal)
 	stop := make(chan struct{})
 	signal.Notify(signals, os.Interrupt, os.Kill)
-	operator, err := operator.NewAEMController(cfg, logger)
+	operator, err := operator.NewAEMController(cfg, operatorConfig, logger)
 	if err != nil {
 		return err
 	}
+	go loader.Watch(configReloadInterval, stop, logger.Sugar())
 	go operator.Run(stop)
 	<-signals
 	close(stop)
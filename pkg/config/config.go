@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"text/template"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Config defaults
+const (
+	DefaultWorkers       = 1
+	DefaultStorageClass  = "gp2"
+	SecretBackendVault   = "vault"
+	DefaultSecretBackend = SecretBackendVault
+	DefaultAPIAddress    = ":8080"
+)
+
+// Config is the configuration of the operator. It is read from a file, usually a mounted ConfigMap,
+// and overridden by the environment and the flags of the operator.
+type Config struct {
+	// Namespaces watched by the operator, every namespace when empty and without a selector.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector is a label selector of namespaces also watched by the operator.
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// Workers is the number of deployments synced at the same time.
+	Workers int `json:"workers,omitempty"`
+	// ExternalDomain is the domain of the hosts of the instances.
+	ExternalDomain string `json:"externalDomain,omitempty"`
+	// Registry of the AEM, dispatcher and sidecar images.
+	Registry string `json:"registry,omitempty"`
+	// ImagePullSecrets of the registry added to every pod.
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+	// DefaultStorageClass of the volume claims of the instances.
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty"`
+	// Secrets is the backend storing the credentials of the deployments.
+	Secrets SecretsConfig `json:"secrets,omitempty"`
+	// Exposure holds the defaults of the values not set in spec.exposure.
+	Exposure ExposureConfig `json:"exposure,omitempty"`
+	// API is the HTTP API of the operator flushing the dispatcher caches.
+	API APIConfig `json:"api,omitempty"`
+}
+
+// APIConfig is the configuration of the operator API.
+type APIConfig struct {
+	Address string `json:"address,omitempty"`
+	// Token is the bearer token of the requests, the API is not served without it. It is only read
+	// from OPERATOR_API_TOKEN to keep it out of the ConfigMap.
+	Token string `json:"-"`
+}
+
+// SecretsConfig is the configuration of the secret backend.
+type SecretsConfig struct {
+	// Backend is the secret backend, only "vault" is supported.
+	Backend string      `json:"backend,omitempty"`
+	Vault   VaultConfig `json:"vault,omitempty"`
+}
+
+// VaultConfig is the configuration of the vault backend.
+type VaultConfig struct {
+	Address string `json:"address,omitempty"`
+	// Token is only read from VAULT_TOKEN to keep it out of the ConfigMap.
+	Token string `json:"-"`
+}
+
+// ExposureConfig holds the exposure defaults of the operator.
+type ExposureConfig struct {
+	IngressClass  string `json:"ingressClass,omitempty"`
+	HostTemplate  string `json:"hostTemplate,omitempty"`
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// Annotations replace the default ingress annotations when set.
+	Annotations map[string]string            `json:"annotations,omitempty"`
+	Issuer      *aemv1beta1.IssuerReference  `json:"issuer,omitempty"`
+	Mode        aemv1beta1.ExposureMode      `json:"mode,omitempty"`
+	Gateway     *aemv1beta1.GatewayReference `json:"gateway,omitempty"`
+	// IngressControllerNamespace is allowed by the network policies of the public instances.
+	IngressControllerNamespace string `json:"ingressControllerNamespace,omitempty"`
+}
+
+// Default returns the configuration used for the settings not set in the file, the environment
+// or the flags.
+func Default() *Config {
+	return &Config{
+		Workers:             DefaultWorkers,
+		DefaultStorageClass: DefaultStorageClass,
+		Secrets:             SecretsConfig{Backend: DefaultSecretBackend},
+		API:                 APIConfig{Address: DefaultAPIAddress},
+	}
+}
+
+// current is the configuration in use, replaced on every reload
+var current atomic.Value
+
+// Get returns the configuration in use, the default configuration if none was set.
+// The returned configuration is shared and must not be modified.
+func Get() *Config {
+	if cfg, _ := current.Load().(*Config); cfg != nil {
+		return cfg
+	}
+	return Default()
+}
+
+// Set replaces the configuration in use, nil restores the default configuration.
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+// Validate checks the configuration at startup and before every reload.
+func (c *Config) Validate() error {
+	if c.ExternalDomain == "" {
+		return fmt.Errorf("externalDomain is required")
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+	if c.NamespaceSelector != "" {
+		_, err := labels.Parse(c.NamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("invalid namespaceSelector: %v", err)
+		}
+	}
+	switch c.Secrets.Backend {
+	case SecretBackendVault:
+		if c.Secrets.Vault.Address == "" {
+			return fmt.Errorf("secrets: vault address is required")
+		}
+		if c.Secrets.Vault.Token == "" {
+			return fmt.Errorf("secrets: vault token is required")
+		}
+	default:
+		return fmt.Errorf("secrets: unknown backend %q", c.Secrets.Backend)
+	}
+	err := c.Exposure.validate()
+	if err != nil {
+		return fmt.Errorf("exposure: %v", err)
+	}
+	return nil
+}
+
+func (e *ExposureConfig) validate() error {
+	if e.HostTemplate != "" {
+		_, err := template.New("host").Parse(e.HostTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid host template: %v", err)
+		}
+	}
+	if issuer := e.Issuer; issuer != nil {
+		if issuer.Name == "" {
+			return fmt.Errorf("issuer has no name")
+		}
+		if issuer.Kind != "" && issuer.Kind != "Issuer" && issuer.Kind != "ClusterIssuer" {
+			return fmt.Errorf("unknown issuer kind %q", issuer.Kind)
+		}
+	}
+	switch e.Mode {
+	case "", aemv1beta1.ExposureModeIngress:
+	case aemv1beta1.ExposureModeGatewayAPI:
+		if e.Gateway == nil || e.Gateway.Name == "" {
+			return fmt.Errorf("the GatewayAPI mode requires a gateway")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", e.Mode)
+	}
+	return nil
+}
+
+// withRuntimeSettings returns a copy of the configuration keeping the settings of running that
+// can not change without restarting the operator: the namespaces, the workers, the secrets and
+// the API.
+func (c *Config) withRuntimeSettings(running *Config) (*Config, bool) {
+	cfg := *c
+	changed := strings.Join(cfg.Namespaces, ",") != strings.Join(running.Namespaces, ",") ||
+		cfg.NamespaceSelector != running.NamespaceSelector ||
+		cfg.Workers != running.Workers || cfg.Secrets != running.Secrets || cfg.API != running.API
+	cfg.Namespaces, cfg.NamespaceSelector = running.Namespaces, running.NamespaceSelector
+	cfg.Workers, cfg.Secrets, cfg.API = running.Workers, running.Secrets, running.API
+	return &cfg, changed
+}
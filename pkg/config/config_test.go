@@ -0,0 +1,137 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+)
+
+const testConfig = `
+namespaces: [aem]
+externalDomain: grid.example.com
+registry: registry.example.com
+secrets:
+  vault:
+    address: https://vault:8200
+exposure:
+  ingressClass: nginx
+  gateway:
+    name: public
+`
+
+func writeConfig(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "aem-operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	os.Setenv(EnvVaultToken, "token")
+	os.Setenv(EnvRegistry, "registry.internal")
+	os.Setenv(EnvGateway, "gateways/internal")
+	os.Setenv(EnvAPIToken, "api-token")
+	defer os.Unsetenv(EnvAPIToken)
+	defer os.Unsetenv(EnvVaultToken)
+	defer os.Unsetenv(EnvRegistry)
+	defer os.Unsetenv(EnvGateway)
+
+	loader := &Loader{Path: path, Overrides: func(c *Config) { c.Workers = 4 }}
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Registry != "registry.internal" || cfg.Secrets.Vault.Token != "token" || cfg.Workers != 4 {
+		t.Errorf("env and flags not applied: %+v", cfg)
+	}
+	if cfg.API != (APIConfig{Address: DefaultAPIAddress, Token: "api-token"}) {
+		t.Errorf("got api: %+v", cfg.API)
+	}
+	if cfg.DefaultStorageClass != DefaultStorageClass || cfg.Secrets.Backend != SecretBackendVault {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+	gateway := aemv1beta1.GatewayReference{Name: "internal", Namespace: "gateways"}
+	if cfg.Exposure.IngressClass != "nginx" || !reflect.DeepEqual(*cfg.Exposure.Gateway, gateway) {
+		t.Errorf("got exposure: %+v", cfg.Exposure)
+	}
+
+	_, err = (&Loader{Path: writeConfig(t, "externalDomain: grid.example.com\nworker: 2\n")}).Load()
+	if err == nil {
+		t.Error("expected error with an unknown field")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := Default()
+		cfg.ExternalDomain = "grid.example.com"
+		cfg.Secrets.Vault = VaultConfig{Address: "https://vault:8200", Token: "token"}
+		return cfg
+	}
+	table := []struct {
+		update func(*Config)
+		valid  bool
+	}{
+		{update: func(c *Config) {}, valid: true},
+		{update: func(c *Config) { c.ExternalDomain = "" }},
+		{update: func(c *Config) { c.Workers = 0 }},
+		{update: func(c *Config) { c.Namespaces = []string{"aem", "site"}; c.NamespaceSelector = "tenant=acme" }, valid: true},
+		{update: func(c *Config) { c.NamespaceSelector = "tenant in (acme" }},
+		{update: func(c *Config) { c.Secrets.Vault.Token = "" }},
+		{update: func(c *Config) { c.Secrets.Backend = "aws" }},
+		{update: func(c *Config) { c.Exposure.HostTemplate = "{{.Instance" }},
+		{update: func(c *Config) { c.Exposure.Mode = aemv1beta1.ExposureModeGatewayAPI }},
+		{update: func(c *Config) { c.Exposure.Issuer = &aemv1beta1.IssuerReference{Name: "ca", Kind: "Vault"} }},
+	}
+	for n, i := range table {
+		cfg := valid()
+		i.update(cfg)
+		err := cfg.Validate()
+		if (err == nil) != i.valid {
+			t.Errorf("%d got: %v", n, err)
+		}
+	}
+}
+
+func TestWithRuntimeSettings(t *testing.T) {
+	running := Default()
+	running.Namespaces = []string{"aem"}
+	reloaded := Default()
+	reloaded.Namespaces = []string{"aem"}
+	reloaded.Registry = "registry.example.com"
+	cfg, changed := reloaded.withRuntimeSettings(running)
+	if changed || cfg.Registry != "registry.example.com" {
+		t.Errorf("got: %v %+v", changed, cfg)
+	}
+	reloaded.Namespaces = nil
+	reloaded.Workers = 8
+	reloaded.API.Address = ":9090"
+	cfg, changed = reloaded.withRuntimeSettings(running)
+	if !changed || cfg.Workers != DefaultWorkers || !reflect.DeepEqual(cfg.Namespaces, running.Namespaces) || cfg.API != running.API {
+		t.Errorf("got: %v %+v", changed, cfg)
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	annotations := parseAnnotations("kubernetes.io/tls-acme=true, nginx.ingress.kubernetes.io/whitelist-source-range=10.0.0.0/8,,empty")
+	expected := map[string]string{
+		"kubernetes.io/tls-acme":                             "true",
+		"nginx.ingress.kubernetes.io/whitelist-source-range": "10.0.0.0/8",
+		"empty": "",
+	}
+	if !reflect.DeepEqual(annotations, expected) {
+		t.Errorf("got: %v exected: %v", annotations, expected)
+	}
+}
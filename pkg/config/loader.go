@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"go.uber.org/zap"
+	"sigs.k8s.io/yaml"
+)
+
+// Environment variables overriding the configuration file
+const (
+	EnvNamespaces                 = "GRID_NAMESPACES"
+	EnvDevNamespace               = "DEV_OPERATOR_NAMESPACE"
+	EnvNamespaceSelector          = "GRID_NAMESPACE_SELECTOR"
+	EnvWorkers                    = "GRID_WORKERS"
+	EnvExternalDomain             = "GRID_EXTERNAL_DOMAIN"
+	EnvRegistry                   = "GRID_REGISTRY"
+	EnvImagePullSecrets           = "GRID_IMAGE_PULL_SECRETS"
+	EnvDefaultStorageClass        = "GRID_DEFAULT_STORAGE_CLASS"
+	EnvSecretBackend              = "GRID_SECRET_BACKEND"
+	EnvVaultAddr                  = "VAULT_ADDR"
+	EnvVaultToken                 = "VAULT_TOKEN"
+	EnvIngressClass               = "GRID_INGRESS_CLASS"
+	EnvIngressHostTemplate        = "GRID_INGRESS_HOST_TEMPLATE"
+	EnvIngressTLSSecret           = "GRID_INGRESS_TLS_SECRET"
+	EnvIngressAnnotations         = "GRID_INGRESS_ANNOTATIONS"
+	EnvIngressIssuer              = "GRID_INGRESS_ISSUER"
+	EnvIngressIssuerKind          = "GRID_INGRESS_ISSUER_KIND"
+	EnvExposureMode               = "GRID_EXPOSURE_MODE"
+	EnvGateway                    = "GRID_GATEWAY"
+	EnvGatewayListener            = "GRID_GATEWAY_LISTENER"
+	EnvIngressControllerNamespace = "GRID_INGRESS_CONTROLLER_NAMESPACE"
+	EnvAPIAddr                    = "OPERATOR_API_ADDR"
+	EnvAPIToken                   = "OPERATOR_API_TOKEN"
+)
+
+// Loader reads the configuration file and applies the overrides of the environment and the flags,
+// in that order.
+type Loader struct {
+	// Path of the configuration file, only the overrides are used when empty.
+	Path string
+	// Overrides applies the flags of the operator.
+	Overrides func(*Config)
+}
+
+// Load returns the validated configuration.
+func (l *Loader) Load() (*Config, error) {
+	data, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	return l.parse(data)
+}
+
+func (l *Loader) read() ([]byte, error) {
+	if l.Path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the configuration: %v", err)
+	}
+	return data, nil
+}
+
+func (l *Loader) parse(data []byte) (*Config, error) {
+	cfg := Default()
+	err := yaml.UnmarshalStrict(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", l.Path, err)
+	}
+	err = applyEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if l.Overrides != nil {
+		l.Overrides(cfg)
+	}
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	return cfg, nil
+}
+
+// Watch checks the configuration file every interval and replaces the configuration in use when it
+// changes. Invalid configurations are ignored, and the namespaces, the workers, the secrets and the
+// API keep their values until the operator restarts.
+func (l *Loader) Watch(interval time.Duration, stop <-chan struct{}, logger *zap.SugaredLogger) {
+	if l.Path == "" {
+		return
+	}
+	last, _ := l.read()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		data, err := l.read()
+		if err != nil {
+			logger.Errorf("Error reloading the configuration: %v", err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		cfg, err := l.parse(data)
+		if err != nil {
+			logger.Errorf("Keeping the current configuration: %v", err)
+			continue
+		}
+		cfg, changed := cfg.withRuntimeSettings(Get())
+		if changed {
+			logger.Warn("The namespaces, workers, secrets and API are applied after restarting the operator")
+		}
+		Set(cfg)
+		logger.Infof("Configuration reloaded from %s", l.Path)
+	}
+}
+
+// applyEnv overrides the configuration with the environment variables that are set
+func applyEnv(cfg *Config) error {
+	if value := os.Getenv(EnvDevNamespace); value != "" {
+		cfg.Namespaces = []string{value}
+	}
+	if value := os.Getenv(EnvNamespaces); value != "" {
+		cfg.Namespaces = SplitList(value)
+	}
+	setFromEnv(&cfg.NamespaceSelector, EnvNamespaceSelector)
+	if value := os.Getenv(EnvWorkers); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", EnvWorkers, err)
+		}
+		cfg.Workers = workers
+	}
+	setFromEnv(&cfg.ExternalDomain, EnvExternalDomain)
+	setFromEnv(&cfg.Registry, EnvRegistry)
+	if value := os.Getenv(EnvImagePullSecrets); value != "" {
+		cfg.ImagePullSecrets = SplitList(value)
+	}
+	setFromEnv(&cfg.DefaultStorageClass, EnvDefaultStorageClass)
+	setFromEnv(&cfg.Secrets.Backend, EnvSecretBackend)
+	setFromEnv(&cfg.Secrets.Vault.Address, EnvVaultAddr)
+	setFromEnv(&cfg.Secrets.Vault.Token, EnvVaultToken)
+	setFromEnv(&cfg.API.Address, EnvAPIAddr)
+	setFromEnv(&cfg.API.Token, EnvAPIToken)
+
+	exposure := &cfg.Exposure
+	setFromEnv(&exposure.IngressClass, EnvIngressClass)
+	setFromEnv(&exposure.HostTemplate, EnvIngressHostTemplate)
+	setFromEnv(&exposure.TLSSecretName, EnvIngressTLSSecret)
+	setFromEnv(&exposure.IngressControllerNamespace, EnvIngressControllerNamespace)
+	if value := os.Getenv(EnvExposureMode); value != "" {
+		exposure.Mode = aemv1beta1.ExposureMode(value)
+	}
+	if value := os.Getenv(EnvIngressAnnotations); value != "" {
+		exposure.Annotations = parseAnnotations(value)
+	}
+	if value := os.Getenv(EnvIngressIssuer); value != "" {
+		exposure.Issuer = &aemv1beta1.IssuerReference{Name: value, Kind: os.Getenv(EnvIngressIssuerKind)}
+	}
+	// the gateway is set as "<namespace>/<name>"
+	if value := os.Getenv(EnvGateway); value != "" {
+		exposure.Gateway = &aemv1beta1.GatewayReference{Name: value, SectionName: os.Getenv(EnvGatewayListener)}
+		if kv := strings.SplitN(value, "/", 2); len(kv) == 2 {
+			exposure.Gateway.Namespace, exposure.Gateway.Name = kv[0], kv[1]
+		}
+	}
+	return nil
+}
+
+func setFromEnv(field *string, name string) {
+	if value := os.Getenv(name); value != "" {
+		*field = value
+	}
+}
+
+// SplitList splits a comma separated list, ignoring the empty values
+func SplitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseAnnotations parses a comma separated list of key=value pairs
+func parseAnnotations(value string) map[string]string {
+	annotations := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if kv[0] == "" {
+			continue
+		}
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		annotations[kv[0]] = kv[1]
+	}
+	return annotations
+}
@@ -0,0 +1,69 @@
+package dispatcher
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// InvalidatePath is the handler of the flush requests of the dispatcher.
+const InvalidatePath = "/dispatcher/invalidate.cache"
+
+// Flush actions sent in the CQ-Action header.
+const (
+	// ActionActivate invalidates the cached files of a content path, they are
+	// requested again from the renders.
+	ActionActivate = "Activate"
+	// ActionDelete deletes the cached files of a content path.
+	ActionDelete = "Delete"
+)
+
+// FlushClient sends flush requests to dispatchers.
+type FlushClient struct {
+	HTTPClient *http.Client
+}
+
+// NewFlushClient returns a client whose requests time out after the given duration.
+func NewFlushClient(timeout time.Duration) *FlushClient {
+	return &FlushClient{HTTPClient: &http.Client{Timeout: timeout}}
+}
+
+// Invalidate invalidates the cached files of the content paths in the dispatcher at baseURL
+// e.g. http://10.0.0.12:80, following the invalidate rules of the farm.
+func (c *FlushClient) Invalidate(baseURL string, paths []string) error {
+	for _, path := range paths {
+		err := c.Flush(baseURL, ActionActivate, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge deletes every cached file of the dispatcher at baseURL.
+func (c *FlushClient) Purge(baseURL string) error {
+	return c.Flush(baseURL, ActionDelete, "/")
+}
+
+// Flush sends a flush request with the given action for a content path.
+func (c *FlushClient) Flush(baseURL, action, handle string) error {
+	req, err := http.NewRequest(http.MethodPost, baseURL+InvalidatePath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("CQ-Action", action)
+	req.Header.Set("CQ-Handle", handle)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned %s", action, handle, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package dispatcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlushClient(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != InvalidatePath || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		handle := r.Header.Get("CQ-Handle")
+		requests = append(requests, r.Header.Get("CQ-Action")+" "+handle)
+		if strings.HasPrefix(handle, "/apps") {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	client := NewFlushClient(time.Second)
+	err := client.Invalidate(server.URL, []string{"/content/site/en", "/content/dam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Purge(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Activate /content/site/en,Activate /content/dam,Delete /"
+	if strings.Join(requests, ",") != expected {
+		t.Errorf("got: %v exected: %v", requests, expected)
+	}
+
+	err = client.Invalidate(server.URL, []string{"/apps/site", "/content/site"})
+	if err == nil {
+		t.Errorf("expected error for a rejected flush")
+	}
+	if requests[len(requests)-1] != "Activate /apps/site" {
+		t.Errorf("flush not stopped after an error: %v", requests)
+	}
+}
@@ -0,0 +1,45 @@
+package dispatcher
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Format returns the entries as a configuration file indented with tabs, comments
+// are not preserved.
+func Format(nodes []*Node) string {
+	var buf bytes.Buffer
+	formatNodes(&buf, nodes, 0)
+	return buf.String()
+}
+
+func formatNodes(buf *bytes.Buffer, nodes []*Node, depth int) {
+	indent := strings.Repeat("\t", depth)
+	for _, node := range nodes {
+		buf.WriteString(indent)
+		switch {
+		case node.Include != "":
+			fmt.Fprintf(buf, "$include \"%s\"\n", node.Include)
+		case node.Name == "":
+			fmt.Fprintf(buf, "%s\n", node.Value)
+		case node.IsBlock():
+			fmt.Fprintf(buf, "/%s {\n", node.Name)
+			formatNodes(buf, node.Children, depth+1)
+			fmt.Fprintf(buf, "%s}\n", indent)
+		default:
+			fmt.Fprintf(buf, "/%s %s\n", node.Name, node.Value)
+		}
+	}
+}
+
+// String returns the value as written in a configuration file.
+func (v *Value) String() string {
+	switch v.Type {
+	case RegexValue:
+		return "'" + v.Text + "'"
+	case BareValue:
+		return v.Text
+	}
+	return `"` + v.Text + `"`
+}
@@ -0,0 +1,266 @@
+// Package dispatcher parses, formats and validates the configuration files of the
+// Adobe dispatcher e.g. dispatcher.any and the farm files.
+package dispatcher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValueType is the type of a value.
+type ValueType int
+
+// Value types.
+const (
+	// StringValue is a value enclosed in double quotes, it may contain globs.
+	StringValue ValueType = iota
+	// RegexValue is a regular expression enclosed in single quotes.
+	RegexValue
+	// BareValue is a value without quotes.
+	BareValue
+)
+
+// Value is the value of a property or a value entry of a block.
+type Value struct {
+	Type ValueType
+	Text string
+}
+
+// Node is an entry of a configuration file: a block e.g. /filter { ... }, a
+// property e.g. /type "allow", a value e.g. "*" in /clientheaders { "*" } or
+// an $include of other files.
+type Node struct {
+	// Name of a block or property without the leading slash, empty for
+	// values and includes.
+	Name string
+	// Value of a property or a value entry.
+	Value *Value
+	// Children of a block, nil for any other entry.
+	Children []*Node
+	// Include is the file pattern of an $include entry.
+	Include string
+	// Line where the entry starts.
+	Line int
+}
+
+// IsBlock returns true if the node is a block.
+func (n *Node) IsBlock() bool {
+	return n.Children != nil
+}
+
+// Child returns the first child with the given name, nil if there is none.
+func (n *Node) Child(name string) *Node {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Property returns the text of the value of the given child property, empty
+// if there is none.
+func (n *Node) Property(name string) string {
+	child := n.Child(name)
+	if child == nil || child.Value == nil {
+		return ""
+	}
+	return child.Value.Text
+}
+
+// SyntaxError is returned when a file can not be parsed.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenName
+	tokenOpen
+	tokenClose
+	tokenString
+	tokenRegex
+	tokenBare
+	tokenInclude
+)
+
+type token struct {
+	typ  tokenType
+	text string
+	line int
+}
+
+// lexer splits a file in tokens, comments start with # and end with the line.
+type lexer struct {
+	input string
+	pos   int
+	line  int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if c == '#' {
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		if c == '\n' {
+			l.line++
+		}
+		if !isSpace(c) {
+			break
+		}
+		l.pos++
+	}
+	if l.pos >= len(l.input) {
+		return token{typ: tokenEOF, line: l.line}, nil
+	}
+	line := l.line
+	switch c := l.input[l.pos]; c {
+	case '{':
+		l.pos++
+		return token{typ: tokenOpen, text: "{", line: line}, nil
+	case '}':
+		l.pos++
+		return token{typ: tokenClose, text: "}", line: line}, nil
+	case '"', '\'':
+		end := strings.IndexAny(l.input[l.pos+1:], string(c)+"\n")
+		if end < 0 || l.input[l.pos+1+end] == '\n' {
+			return token{}, &SyntaxError{Line: line, Msg: fmt.Sprintf("unterminated %c", c)}
+		}
+		text := l.input[l.pos+1 : l.pos+1+end]
+		l.pos += end + 2
+		if c == '\'' {
+			return token{typ: tokenRegex, text: text, line: line}, nil
+		}
+		return token{typ: tokenString, text: text, line: line}, nil
+	case '/':
+		word := l.word()
+		if len(word) == 1 {
+			return token{}, &SyntaxError{Line: line, Msg: "empty name"}
+		}
+		return token{typ: tokenName, text: word[1:], line: line}, nil
+	case '$':
+		word := l.word()
+		if word != "$include" {
+			return token{}, &SyntaxError{Line: line, Msg: fmt.Sprintf("unknown directive %s", word)}
+		}
+		return token{typ: tokenInclude, text: word, line: line}, nil
+	default:
+		return token{typ: tokenBare, text: l.word(), line: line}, nil
+	}
+}
+
+// word reads until a space, a brace or a quote
+func (l *lexer) word() string {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.input) && !isSpace(l.input[l.pos]) && !strings.ContainsRune("{}\"'#", rune(l.input[l.pos])) {
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Parse returns the entries of a configuration file.
+func Parse(data []byte) ([]*Node, error) {
+	p := &parser{lexer: &lexer{input: string(data), line: 1}}
+	nodes, err := p.entries(0)
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+type parser struct {
+	lexer *lexer
+}
+
+func (p *parser) next() (token, error) {
+	return p.lexer.next()
+}
+
+// entries parses entries until the end of the file or the end of the block
+func (p *parser) entries(depth int) ([]*Node, error) {
+	nodes := []*Node{}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch t.typ {
+		case tokenEOF:
+			if depth > 0 {
+				return nil, &SyntaxError{Line: t.line, Msg: "missing }"}
+			}
+			return nodes, nil
+		case tokenClose:
+			if depth == 0 {
+				return nil, &SyntaxError{Line: t.line, Msg: "unexpected }"}
+			}
+			return nodes, nil
+		case tokenOpen:
+			return nil, &SyntaxError{Line: t.line, Msg: "unexpected {"}
+		case tokenString, tokenRegex, tokenBare:
+			nodes = append(nodes, &Node{Value: newValue(t), Line: t.line})
+		case tokenInclude:
+			file, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if file.typ != tokenString {
+				return nil, &SyntaxError{Line: t.line, Msg: "$include requires a quoted file"}
+			}
+			nodes = append(nodes, &Node{Include: file.text, Line: t.line})
+		case tokenName:
+			node, err := p.named(t, depth)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// named parses the block or the value of a name
+func (p *parser) named(name token, depth int) (*Node, error) {
+	node := &Node{Name: name.text, Line: name.line}
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t.typ {
+	case tokenOpen:
+		node.Children, err = p.entries(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+	case tokenString, tokenRegex, tokenBare:
+		node.Value = newValue(t)
+	default:
+		return nil, &SyntaxError{Line: name.line, Msg: fmt.Sprintf("/%s has no value", name.text)}
+	}
+	return node, nil
+}
+
+func newValue(t token) *Value {
+	switch t.typ {
+	case tokenRegex:
+		return &Value{Type: RegexValue, Text: t.text}
+	case tokenBare:
+		return &Value{Type: BareValue, Text: t.text}
+	}
+	return &Value{Type: StringValue, Text: t.text}
+}
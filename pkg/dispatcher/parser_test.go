@@ -0,0 +1,114 @@
+package dispatcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+const farm = `
+# First farm entry
+/website
+	{
+	/clientheaders { "*" }
+	/virtualhosts { "www.example.com" "intranet.*" }
+	/renders
+		{
+		/rend01 { /hostname "${PUBLISH_IP}" /port "${PUBLISH_PORT}" }
+		}
+	/filter
+		{
+		/0001 { /type "deny" /glob "*" }  # deny everything first
+		/0041 { /type "allow" /extension '(css|gif|ico|js|png|swf|jpe?g)' }
+		$include "filters/*.any"
+		}
+	/cache
+		{
+		/docroot "/opt/communique/dispatcher/cache"
+		/statfileslevel 2
+		/rules { /0000 { /glob "*" /type "allow" } }
+		}
+	}
+`
+
+func TestParse(t *testing.T) {
+	nodes, err := Parse([]byte(farm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "website" || nodes[0].Line != 3 {
+		t.Fatalf("got: %+v", nodes)
+	}
+	website := nodes[0]
+	hosts := website.Child("virtualhosts").Children
+	if len(hosts) != 2 || hosts[1].Value.Text != "intranet.*" {
+		t.Errorf("got virtual hosts: %+v", hosts)
+	}
+	filter := website.Child("filter")
+	if len(filter.Children) != 3 || filter.Children[2].Include != "filters/*.any" {
+		t.Errorf("got filters: %+v", filter.Children)
+	}
+	extension := filter.Child("0041").Child("extension").Value
+	if extension.Type != RegexValue || extension.Text != "(css|gif|ico|js|png|swf|jpe?g)" {
+		t.Errorf("got extension: %+v", extension)
+	}
+	level := website.Child("cache").Child("statfileslevel").Value
+	if level.Type != BareValue || level.Text != "2" {
+		t.Errorf("got statfileslevel: %+v", level)
+	}
+	if website.Child("renders").Child("rend01").Property("port") != "${PUBLISH_PORT}" {
+		t.Errorf("got renders: %+v", website.Child("renders"))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	table := []struct {
+		input string
+		line  int
+	}{
+		{input: "/website {\n/filter {\n}\n", line: 4},
+		{input: "/website { }\n}", line: 2},
+		{input: "/website {\n/type \"allow }\n}", line: 2},
+		{input: "/website {\n/type\n}", line: 2},
+		{input: "/website {\n$includes \"farm.any\"\n}", line: 2},
+		{input: "/website {\n$include farm.any\n}", line: 2},
+		{input: "/ { }", line: 1},
+	}
+	for _, i := range table {
+		_, err := Parse([]byte(i.input))
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("got: %v exected a syntax error for %q", err, i.input)
+			continue
+		}
+		if serr.Line != i.line {
+			t.Errorf("got line: %d exected: %d for %q", serr.Line, i.line, i.input)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	nodes, err := Parse([]byte(farm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted := Format(nodes)
+	parsed, err := Parse([]byte(formatted))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, formatted)
+	}
+	if Format(parsed) != formatted {
+		t.Errorf("got: %s exected: %s", Format(parsed), formatted)
+	}
+	clearLines(nodes)
+	clearLines(parsed)
+	if !reflect.DeepEqual(nodes, parsed) {
+		t.Errorf("formatted entries differ: %s", formatted)
+	}
+}
+
+func clearLines(nodes []*Node) {
+	for _, node := range nodes {
+		node.Line = 0
+		clearLines(node.Children)
+	}
+}
@@ -0,0 +1,142 @@
+package dispatcher
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule types.
+const (
+	RuleAllow = "allow"
+	RuleDeny  = "deny"
+)
+
+// filterProperties are the properties of a filter rule, every property but the type
+// matches the request line or one of its elements.
+var filterProperties = []string{"type", "glob", "method", "url", "query", "protocol", "path", "selectors", "extension", "suffix"}
+
+// globRuleSections are the blocks whose entries are glob rules.
+var globRuleSections = []string{"rules", "invalidate", "allowedClients", "ignoreUrlParams"}
+
+// Validate checks the filters, the cache rules and the renders of the entries, the
+// $include entries must be expanded to validate the included entries.
+func Validate(nodes []*Node) error {
+	for _, node := range nodes {
+		if !node.IsBlock() {
+			continue
+		}
+		var err error
+		switch {
+		case node.Name == "filter":
+			err = validateRules(node, filterProperties)
+		case isIn(node.Name, globRuleSections):
+			err = validateRules(node, []string{"type", "glob"})
+		case node.Name == "renders":
+			err = validateRenders(node)
+		}
+		if err != nil {
+			return err
+		}
+		err = Validate(node.Children)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRules checks that the entries of a block are rules with a type, at least one
+// matching property and valid regular expressions.
+func validateRules(block *Node, properties []string) error {
+	for _, rule := range block.Children {
+		if rule.Include != "" {
+			continue
+		}
+		if !rule.IsBlock() {
+			return fmt.Errorf("line %d: the entries of /%s must be rules", rule.Line, block.Name)
+		}
+		ruleType := rule.Property("type")
+		if ruleType != RuleAllow && ruleType != RuleDeny {
+			return fmt.Errorf("line %d: /%s/%s has an invalid type %q", rule.Line, block.Name, rule.Name, ruleType)
+		}
+		matchers := 0
+		for _, property := range rule.Children {
+			if property.Include != "" {
+				continue
+			}
+			if !isIn(property.Name, properties) || property.Value == nil {
+				return fmt.Errorf("line %d: unknown property /%s in /%s/%s", property.Line, property.Name, block.Name, rule.Name)
+			}
+			if property.Name != "type" {
+				matchers++
+			}
+			if property.Value.Type == RegexValue {
+				_, err := regexp.Compile(property.Value.Text)
+				if err != nil {
+					return fmt.Errorf("line %d: invalid regular expression '%s': %v", property.Line, property.Value.Text, err)
+				}
+			}
+		}
+		if matchers == 0 {
+			return fmt.Errorf("line %d: /%s/%s matches nothing", rule.Line, block.Name, rule.Name)
+		}
+	}
+	return nil
+}
+
+// validateRenders checks that every render has a hostname and a port.
+func validateRenders(block *Node) error {
+	for _, render := range block.Children {
+		if render.Include != "" {
+			continue
+		}
+		if !render.IsBlock() {
+			return fmt.Errorf("line %d: the entries of /renders must be blocks", render.Line)
+		}
+		if render.Property("hostname") == "" {
+			return fmt.Errorf("line %d: /renders/%s has no hostname", render.Line, render.Name)
+		}
+		port := render.Property("port")
+		if _, err := strconv.Atoi(port); err != nil && !strings.HasPrefix(port, "${") {
+			return fmt.Errorf("line %d: /renders/%s has an invalid port %q", render.Line, render.Name, port)
+		}
+	}
+	return nil
+}
+
+// Expand replaces the $include entries with the entries returned by include for their file pattern.
+func Expand(nodes []*Node, include func(pattern string) ([]*Node, error)) ([]*Node, error) {
+	expanded := []*Node{}
+	for _, node := range nodes {
+		if node.Include != "" {
+			included, err := include(node.Include)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", node.Line, err)
+			}
+			expanded = append(expanded, included...)
+			continue
+		}
+		if node.IsBlock() {
+			children, err := Expand(node.Children, include)
+			if err != nil {
+				return nil, err
+			}
+			copied := *node
+			copied.Children = children
+			node = &copied
+		}
+		expanded = append(expanded, node)
+	}
+	return expanded, nil
+}
+
+func isIn(s string, values []string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package dispatcher
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	table := []struct {
+		input string
+		valid bool
+	}{
+		{input: farm, valid: true},
+		{input: `/filter { /0001 { /type "block" /glob "*" } }`, valid: false},
+		{input: `/filter { /0001 { /type "allow" } }`, valid: false},
+		{input: `/filter { /0001 { /type "allow" /uri "/content/*" } }`, valid: false},
+		{input: `/filter { /0001 { /type "allow" /extension '(css|js' } }`, valid: false},
+		{input: `/filter { "*" }`, valid: false},
+		{input: `/cache { /rules { /0000 { /glob "*" /type "allow" } } }`, valid: true},
+		{input: `/cache { /invalidate { /0000 { /type "allow" } } }`, valid: false},
+		{input: `/cache { /headers { "Cache-Control" "Content-Type" } }`, valid: true},
+		{input: `/renders { /rend01 { /hostname "publish" /port "4503" } }`, valid: true},
+		{input: `/renders { /rend01 { /port "4503" } }`, valid: false},
+		{input: `/renders { /rend01 { /hostname "publish" /port "http" } }`, valid: false},
+	}
+	for _, i := range table {
+		nodes, err := Parse([]byte(i.input))
+		if err != nil {
+			t.Fatalf("%v: %s", err, i.input)
+		}
+		err = Validate(nodes)
+		if (err == nil) != i.valid {
+			t.Errorf("got: %v exected valid: %v for %s", err, i.valid, i.input)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	nodes, err := Parse([]byte(farm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	included, _ := Parse([]byte(`/0100 { /type "allow" /url "/content/*" }`))
+	expanded, err := Expand(nodes, func(pattern string) ([]*Node, error) {
+		return included, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := expanded[0].Child("filter")
+	if len(filter.Children) != 3 || filter.Child("0100") == nil {
+		t.Errorf("got filters: %+v", filter.Children)
+	}
+	if len(nodes[0].Child("filter").Children) != 3 || nodes[0].Child("filter").Children[2].Include == "" {
+		t.Errorf("the expanded entries were modified")
+	}
+}
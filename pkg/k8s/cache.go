@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+)
+
+// Dispatcher cache constants
+const (
+	DispatcherCacheDir        = "/opt/communique/dispatcher/cache"
+	DispatcherCacheVolumeName = "dispatcher-cache"
+)
+
+// dispatcherCache returns the cache settings of the dispatchers, nil if not set
+func dispatcherCache(deployment *aemv1beta1.AEMDeployment) *aemv1beta1.DispatcherCacheSpec {
+	if deployment.Spec.Dispatcher == nil {
+		return nil
+	}
+	return deployment.Spec.Dispatcher.Cache
+}
+
+// dispatcherCacheVolume returns the volume of the dispatcher cache, nil if the cache is kept in
+// the container filesystem. Hardened dispatchers have a read only root filesystem so their
+// cache is always a volume.
+func dispatcherCacheVolume(podName string, deployment *aemv1beta1.AEMDeployment) *v1.Volume {
+	cache := dispatcherCache(deployment)
+	if cache == nil {
+		if deployment.Spec.Security == nil || !deployment.Spec.Security.Hardened {
+			return nil
+		}
+		cache = &aemv1beta1.DispatcherCacheSpec{}
+	}
+	volume := v1.Volume{Name: DispatcherCacheVolumeName}
+	switch cache.Medium {
+	case aemv1beta1.DispatcherCachePersistentVolume:
+		// the claim created for every instance is kept when the dispatcher is restarted
+		volume.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{
+			ClaimName: MakePVCName(podName),
+		}
+	case aemv1beta1.DispatcherCacheMemory:
+		volume.EmptyDir = &v1.EmptyDirVolumeSource{
+			Medium:    v1.StorageMediumMemory,
+			SizeLimit: cache.SizeLimit,
+		}
+	default:
+		volume.EmptyDir = &v1.EmptyDirVolumeSource{
+			SizeLimit: cache.SizeLimit,
+		}
+	}
+	return &volume
+}
+
+// applyDispatcherCache mounts the cache volume in the dispatcher container
+func applyDispatcherCache(pod *v1.Pod, deployment *aemv1beta1.AEMDeployment) {
+	volume := dispatcherCacheVolume(pod.Name, deployment)
+	if volume == nil {
+		return
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, *volume)
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if container.Name != makeVolumeKey(deployment.Name, "dispatcher") {
+			continue
+		}
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      DispatcherCacheVolumeName,
+			MountPath: DispatcherCacheDir,
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestApplyDispatcherCache(t *testing.T) {
+	size := resource.MustParse("2Gi")
+	table := []struct {
+		cache    *aemv1beta1.DispatcherCacheSpec
+		hardened bool
+		check    func(volume *v1.Volume) bool
+	}{
+		{cache: nil, check: func(volume *v1.Volume) bool { return volume == nil }},
+		{cache: nil, hardened: true, check: func(volume *v1.Volume) bool {
+			return volume != nil && volume.EmptyDir != nil && volume.EmptyDir.SizeLimit == nil
+		}},
+		{cache: &aemv1beta1.DispatcherCacheSpec{SizeLimit: &size}, check: func(volume *v1.Volume) bool {
+			return volume != nil && volume.EmptyDir != nil && volume.EmptyDir.SizeLimit.Cmp(size) == 0
+		}},
+		{cache: &aemv1beta1.DispatcherCacheSpec{Medium: aemv1beta1.DispatcherCacheMemory}, check: func(volume *v1.Volume) bool {
+			return volume != nil && volume.EmptyDir != nil && volume.EmptyDir.Medium == v1.StorageMediumMemory
+		}},
+		{cache: &aemv1beta1.DispatcherCacheSpec{Medium: aemv1beta1.DispatcherCachePersistentVolume}, hardened: true, check: func(volume *v1.Volume) bool {
+			return volume != nil && volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == "dev-dispatcher-001-pvc"
+		}},
+	}
+	for _, i := range table {
+		deployment := &aemv1beta1.AEMDeployment{}
+		deployment.Name = "dev"
+		deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{Cache: i.cache}
+		deployment.Spec.Security = &aemv1beta1.SecuritySpec{Hardened: i.hardened}
+		pod := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+		var volume *v1.Volume
+		volumes := 0
+		for j := range pod.Spec.Volumes {
+			if pod.Spec.Volumes[j].Name == DispatcherCacheVolumeName {
+				volume = &pod.Spec.Volumes[j]
+				volumes++
+			}
+		}
+		if !i.check(volume) || volumes > 1 {
+			t.Errorf("got %d cache volumes: %+v for %+v", volumes, volume, i.cache)
+		}
+		for _, container := range pod.Spec.Containers {
+			mounted := false
+			for _, mount := range container.VolumeMounts {
+				mounted = mounted || (mount.Name == DispatcherCacheVolumeName && mount.MountPath == DispatcherCacheDir)
+			}
+			if mounted != (volume != nil && container.Name == "dev-dispatcher") {
+				t.Errorf("got mounted: %v in %s for %+v", mounted, container.Name, i.cache)
+			}
+		}
+	}
+}
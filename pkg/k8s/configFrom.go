@@ -0,0 +1,439 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/dispatcher"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// User provided dispatcher configuration constants
+const (
+	DefaultDispatcherConfigMountPath = "/usr/local/apache2"
+	dispatcherConfigVolumeName       = "dispatcher-config"
+	dispatcherConfigSourceVolumeName = "dispatcher-config-source"
+	dispatcherConfigSourceDir        = "/config-source"
+	dispatcherConfigDir              = "/config"
+	envTarballKey                    = "TARBALL_KEY"
+	envServerRoot                    = "SERVER_ROOT"
+
+	// dispatcherConfigInitScript copies the server root of the image into the config volume and
+	// overlays the configuration tree from the source volume, extracting the tarball or mapping
+	// "__" in the keys to directories.
+	dispatcherConfigInitScript = `set -e
+cp -a "$SERVER_ROOT/." /config/
+if [ -n "$TARBALL_KEY" ]; then
+  (gzip -dc "/config-source/$TARBALL_KEY" 2>/dev/null || cat "/config-source/$TARBALL_KEY") | tar -x -C /config
+  exit 0
+fi
+for f in /config-source/*; do
+  target="/config/$(basename "$f" | sed 's|__|/|g')"
+  mkdir -p "$(dirname "$target")"
+  cp "$f" "$target"
+done`
+)
+
+var (
+	apacheIncludeRegexp     = regexp.MustCompile(`(?i)^\s*(Include|IncludeOptional|DispatcherConfig)\s+"?([^"\s]+)"?`)
+	dispatcherIncludeRegexp = regexp.MustCompile(`\$include\s+"([^"]+)"`)
+)
+
+// dispatcherConfigSource returns the user provided configuration of the dispatchers, nil if
+// the configuration is generated by the operator
+func dispatcherConfigSource(deployment *aemv1beta1.AEMDeployment) *aemv1beta1.DispatcherConfigSource {
+	if deployment.Spec.Dispatcher == nil {
+		return nil
+	}
+	return deployment.Spec.Dispatcher.ConfigFrom
+}
+
+// dispatcherConfigMountPath returns the server root of Apache the configuration tree is overlaid on
+func dispatcherConfigMountPath(source *aemv1beta1.DispatcherConfigSource) string {
+	if source.MountPath == "" {
+		return DefaultDispatcherConfigMountPath
+	}
+	return strings.TrimSuffix(source.MountPath, "/")
+}
+
+// applyDispatcherConfigFrom replaces the generated configuration of a dispatcher pod with the user
+// provided configuration tree. An init container copies the server root of the image into an
+// emptyDir and overlays the tree, so Apache reads the tree from the directories it includes and
+// keeps its binaries and modules. The server root stays writable for the logs, the pid and the
+// mutex files of httpd. The sidecar mounts the files set by SetDispatcherConfigFiles.
+func applyDispatcherConfigFrom(pod *v1.Pod, deployment *aemv1beta1.AEMDeployment) {
+	source := dispatcherConfigSource(deployment)
+	if source == nil {
+		return
+	}
+	mountPath := dispatcherConfigMountPath(source)
+
+	sourceVolume := v1.Volume{Name: dispatcherConfigSourceVolumeName}
+	if source.SecretName != "" {
+		sourceVolume.Secret = &v1.SecretVolumeSource{SecretName: source.SecretName}
+	} else {
+		sourceVolume.ConfigMap = &v1.ConfigMapVolumeSource{
+			LocalObjectReference: v1.LocalObjectReference{Name: source.ConfigMapName},
+		}
+	}
+	volumes := []v1.Volume{sourceVolume, emptyDirVolume(dispatcherConfigVolumeName)}
+	for _, volume := range pod.Spec.Volumes {
+		if !isGeneratedConfigVolume(volume.Name) {
+			volumes = append(volumes, volume)
+		}
+	}
+	pod.Spec.Volumes = volumes
+
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		mounts := []v1.VolumeMount{}
+		for _, mount := range container.VolumeMounts {
+			if !isGeneratedConfigVolume(mount.Name) {
+				mounts = append(mounts, mount)
+			}
+		}
+		if container.Name == makeVolumeKey(deployment.Name, "dispatcher") {
+			mounts = append(mounts, v1.VolumeMount{
+				Name:      dispatcherConfigVolumeName,
+				MountPath: mountPath,
+			})
+		}
+		container.VolumeMounts = mounts
+	}
+
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, v1.Container{
+		Name:            makeVolumeKey(deployment.Name, "config"),
+		Image:           getFullImageURL(aemDispatcherContainerImage),
+		ImagePullPolicy: imagePullPolicy(deployment),
+		Command:         []string{"/bin/sh", "-c", dispatcherConfigInitScript},
+		Env: []v1.EnvVar{
+			{
+				Name:  envTarballKey,
+				Value: source.TarballKey,
+			},
+			{
+				Name:  envServerRoot,
+				Value: mountPath,
+			},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{
+				Name:      dispatcherConfigSourceVolumeName,
+				MountPath: dispatcherConfigSourceDir,
+				ReadOnly:  true,
+			},
+			{
+				Name:      dispatcherConfigVolumeName,
+				MountPath: dispatcherConfigDir,
+			},
+		},
+	})
+}
+
+// isGeneratedConfigVolume reports whether a volume holds the generated configuration, they are
+// replaced by the configuration tree
+func isGeneratedConfigVolume(name string) bool {
+	switch name {
+	case ConfigVolumeKeySites, ConfigVolumeKeyFarm, dispatcherFarmsVolumeName, dispatcherSitesVolumeName:
+		return true
+	}
+	return false
+}
+
+// ValidateDispatcherConfigFrom checks the user provided configuration tree of the dispatchers
+// before it is rolled out, every Include, DispatcherConfig and $include must reference files
+// of the tree.
+func ValidateDispatcherConfigFrom(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) error {
+	source := dispatcherConfigSource(deployment)
+	if source == nil {
+		return nil
+	}
+	tree, err := loadDispatcherConfigTree(client, deployment.Namespace, source)
+	if err != nil {
+		return fmt.Errorf("dispatcher: %v", err)
+	}
+	err = checkDispatcherIncludes(tree, dispatcherConfigMountPath(source))
+	if err == nil {
+		err = checkDispatcherFarms(tree, dispatcherConfigMountPath(source))
+	}
+	if err != nil {
+		return fmt.Errorf("dispatcher: %v", err)
+	}
+	return nil
+}
+
+// loadDispatcherConfigTree returns the files of the configuration tree by path relative to the mount path
+func loadDispatcherConfigTree(client kubernetes.Interface, ns string, source *aemv1beta1.DispatcherConfigSource) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	if source.SecretName != "" {
+		secret, err := client.CoreV1().Secrets(ns).Get(source.SecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		data = secret.Data
+	} else {
+		cmap, err := client.CoreV1().ConfigMaps(ns).Get(source.ConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range cmap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range cmap.BinaryData {
+			data[key] = value
+		}
+	}
+
+	if source.TarballKey != "" {
+		tarball, ok := data[source.TarballKey]
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", source.TarballKey)
+		}
+		return extractTarball(tarball)
+	}
+	tree := map[string][]byte{}
+	for key, value := range data {
+		tree[strings.Replace(key, "__", "/", -1)] = value
+	}
+	return tree, nil
+}
+
+// extractTarball returns the regular files of a tar or tar.gz archive by path
+func extractTarball(data []byte) (map[string][]byte, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if gz, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+		r = gz
+	}
+	tree := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return tree, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tarball: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tarball: %v", err)
+		}
+		tree[path.Clean(strings.TrimPrefix(header.Name, "./"))] = content
+	}
+}
+
+// checkDispatcherIncludes checks the includes of every file of the tree. Apache includes are
+// relative to the server root (the mount path), dispatcher includes are relative to the including
+// file. Includes of directories without files of the tree or with variables are provided by the
+// image and are skipped.
+func checkDispatcherIncludes(tree map[string][]byte, mountPath string) error {
+	files := []string{}
+	for name := range tree {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		scanner := bufio.NewScanner(bytes.NewReader(tree[name]))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			if m := apacheIncludeRegexp.FindStringSubmatch(line); m != nil {
+				if strings.EqualFold(m[1], "IncludeOptional") || strings.Contains(m[2], "${") {
+					continue
+				}
+				include := m[2]
+				if path.IsAbs(include) {
+					if !strings.HasPrefix(include, mountPath+"/") {
+						continue
+					}
+					include = strings.TrimPrefix(include, mountPath+"/")
+				}
+				include = path.Clean(include)
+				if treeHasDir(tree, path.Dir(include)) && !includeMatches(tree, include) {
+					return fmt.Errorf("%s: %s %s matches no file", name, m[1], m[2])
+				}
+			}
+			for _, m := range dispatcherIncludeRegexp.FindAllStringSubmatch(line, -1) {
+				if strings.Contains(m[1], "${") || path.IsAbs(m[1]) {
+					continue
+				}
+				if !includeMatches(tree, path.Join(path.Dir(name), m[1])) {
+					return fmt.Errorf("%s: $include %q matches no file", name, m[1])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkDispatcherFarms parses every .any and .farm file of the tree and validates the configuration
+// loaded by each DispatcherConfig directive with its includes expanded.
+func checkDispatcherFarms(tree map[string][]byte, mountPath string) error {
+	files := []string{}
+	for name := range tree {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		if strings.HasSuffix(name, ".any") || strings.HasSuffix(name, ".farm") {
+			if _, err := dispatcher.Parse(tree[name]); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	for _, name := range files {
+		scanner := bufio.NewScanner(bytes.NewReader(tree[name]))
+		for scanner.Scan() {
+			m := apacheIncludeRegexp.FindStringSubmatch(scanner.Text())
+			if m == nil || !strings.EqualFold(m[1], "DispatcherConfig") {
+				continue
+			}
+			config := m[2]
+			if path.IsAbs(config) {
+				if !strings.HasPrefix(config, mountPath+"/") {
+					continue
+				}
+				config = strings.TrimPrefix(config, mountPath+"/")
+			}
+			config = path.Clean(config)
+			nodes, err := parseDispatcherFile(tree, config, 0)
+			if err != nil {
+				return err
+			}
+			if err := dispatcher.Validate(nodes); err != nil {
+				return fmt.Errorf("%s: %v", config, err)
+			}
+		}
+	}
+	return nil
+}
+
+// maxIncludeDepth limits the nesting of $include to detect include cycles
+const maxIncludeDepth = 10
+
+// parseDispatcherFile parses a file of the tree expanding its includes, relative to the file
+func parseDispatcherFile(tree map[string][]byte, name string, depth int) ([]*dispatcher.Node, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("%s: too many nested includes", name)
+	}
+	content, ok := tree[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	nodes, err := dispatcher.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return dispatcher.Expand(nodes, func(pattern string) ([]*dispatcher.Node, error) {
+		pattern = path.Join(path.Dir(name), pattern)
+		matches := []string{}
+		for file := range tree {
+			if ok, _ := path.Match(pattern, file); ok {
+				matches = append(matches, file)
+			}
+		}
+		sort.Strings(matches)
+		included := []*dispatcher.Node{}
+		for _, file := range matches {
+			nodes, err := parseDispatcherFile(tree, file, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			included = append(included, nodes...)
+		}
+		return included, nil
+	})
+}
+
+// treeHasDir returns true if the tree has files in the directory
+func treeHasDir(tree map[string][]byte, dir string) bool {
+	for name := range tree {
+		if path.Dir(name) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDispatcherConfigFiles sets and mounts the files cached by the sidecar of a dispatcher pod using
+// the configuration tree, the dispatcher configurations and the virtual hosts of the tree.
+func SetDispatcherConfigFiles(client kubernetes.Interface, pod *v1.Pod, deployment *aemv1beta1.AEMDeployment) error {
+	source := dispatcherConfigSource(deployment)
+	if source == nil {
+		return nil
+	}
+	tree, err := loadDispatcherConfigTree(client, deployment.Namespace, source)
+	if err != nil {
+		return err
+	}
+	mountPath := dispatcherConfigMountPath(source)
+	files := dispatcherConfigFiles(tree, mountPath)
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if container.Name != makeVolumeKey(deployment.Name, "sidecar") {
+			continue
+		}
+		for j := range container.Env {
+			if container.Env[j].Name == envSideCarConfigFiles {
+				container.Env[j].Value = strings.Join(files, ",")
+			}
+		}
+		mounts := []v1.VolumeMount{}
+		for _, mount := range container.VolumeMounts {
+			if mount.Name != dispatcherConfigVolumeName {
+				mounts = append(mounts, mount)
+			}
+		}
+		for _, file := range files {
+			mounts = append(mounts, v1.VolumeMount{
+				Name:      dispatcherConfigVolumeName,
+				MountPath: file,
+				SubPath:   strings.TrimPrefix(file, mountPath+"/"),
+				ReadOnly:  true,
+			})
+		}
+		container.VolumeMounts = mounts
+	}
+	return nil
+}
+
+// dispatcherConfigFiles returns the absolute paths of the .any and .farm files and of the files
+// with virtual hosts of the tree
+func dispatcherConfigFiles(tree map[string][]byte, mountPath string) []string {
+	files := []string{}
+	for name, content := range tree {
+		if strings.HasSuffix(name, ".any") || strings.HasSuffix(name, ".farm") ||
+			bytes.Contains(bytes.ToLower(content), []byte("<virtualhost")) {
+			files = append(files, path.Join(mountPath, name))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// includeMatches returns true if the pattern matches a file of the tree or a directory containing files
+func includeMatches(tree map[string][]byte, pattern string) bool {
+	for name := range tree {
+		if ok, _ := path.Match(pattern, name); ok || strings.HasPrefix(name, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
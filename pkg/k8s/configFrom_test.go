@@ -0,0 +1,202 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"path"
+	"strings"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckDispatcherIncludes(t *testing.T) {
+	tree := map[string][]byte{
+		"conf/httpd.conf":                              []byte("Include conf.d/*.conf\nIncludeOptional conf.d/missing/*.conf\nInclude /usr/local/apache2/conf/extra/mime.conf\n"),
+		"conf.d/dispatcher.conf":                       []byte("DispatcherConfig conf.dispatcher.d/dispatcher.any\n"),
+		"conf.dispatcher.d/dispatcher.any":             []byte("/farms {\n  $include \"enabled_farms/*.farm\"\n}\n"),
+		"conf.dispatcher.d/enabled_farms/default.farm": []byte("/filter { $include \"../filters/filters.any\" }\n# $include \"../missing.any\"\n"),
+		"conf.dispatcher.d/filters/filters.any":        []byte("/0001 { /type \"deny\" /glob \"*\" }\n"),
+	}
+	err := checkDispatcherIncludes(tree, DefaultDispatcherConfigMountPath)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tree["conf.dispatcher.d/enabled_farms/publish.farm"] = []byte("/cache { $include \"../cache/rules.any\" }\n")
+	err = checkDispatcherIncludes(tree, DefaultDispatcherConfigMountPath)
+	if err == nil {
+		t.Errorf("expected error for a missing $include")
+	}
+	delete(tree, "conf.dispatcher.d/enabled_farms/publish.farm")
+
+	tree["conf.d/vhosts.conf"] = []byte("Include /usr/local/apache2/conf.dispatcher.d/vhosts.any\n")
+	err = checkDispatcherIncludes(tree, DefaultDispatcherConfigMountPath)
+	if err == nil {
+		t.Errorf("expected error for a missing Include")
+	}
+}
+
+func TestLoadDispatcherConfigTree(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	content := []byte("DispatcherConfig conf.dispatcher.d/dispatcher.any\n")
+	tw.WriteHeader(&tar.Header{Name: "./conf.d/dispatcher.conf", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+
+	client := fake.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "dispatcher-tree", Namespace: "demo"},
+			Data:       map[string]string{"conf.d__dispatcher.conf": string(content)},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "dispatcher-tarball", Namespace: "demo"},
+			Data:       map[string][]byte{"conf.tgz": buf.Bytes()},
+		},
+	)
+	table := []struct {
+		source aemv1beta1.DispatcherConfigSource
+		valid  bool
+	}{
+		{source: aemv1beta1.DispatcherConfigSource{ConfigMapName: "dispatcher-tree"}, valid: true},
+		{source: aemv1beta1.DispatcherConfigSource{SecretName: "dispatcher-tarball", TarballKey: "conf.tgz"}, valid: true},
+		{source: aemv1beta1.DispatcherConfigSource{SecretName: "dispatcher-tarball", TarballKey: "conf.tar"}, valid: false},
+		{source: aemv1beta1.DispatcherConfigSource{ConfigMapName: "missing"}, valid: false},
+	}
+	for _, i := range table {
+		tree, err := loadDispatcherConfigTree(client, "demo", &i.source)
+		if (err == nil) != i.valid {
+			t.Errorf("got: %v exected valid: %v for %+v", err, i.valid, i.source)
+		}
+		if i.valid && !bytes.Equal(tree["conf.d/dispatcher.conf"], content) {
+			t.Errorf("got: %v for %+v", tree, i.source)
+		}
+	}
+}
+
+func TestApplyDispatcherConfigFrom(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		ConfigFrom: &aemv1beta1.DispatcherConfigSource{ConfigMapName: "dispatcher-tree"},
+	}
+	pod := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	if len(pod.Spec.InitContainers) != 1 {
+		t.Fatalf("got %d init containers exected: 1", len(pod.Spec.InitContainers))
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if isGeneratedConfigVolume(volume.Name) {
+			t.Errorf("generated config volume %s not replaced", volume.Name)
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		mounted := false
+		for _, mount := range container.VolumeMounts {
+			mounted = mounted || (mount.Name == dispatcherConfigVolumeName && mount.MountPath == DefaultDispatcherConfigMountPath)
+		}
+		if mounted != (container.Name == "dev-dispatcher") {
+			t.Errorf("got config tree mounted: %v in %s", mounted, container.Name)
+		}
+	}
+	env := map[string]string{}
+	for _, e := range pod.Spec.InitContainers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env[envServerRoot] != DefaultDispatcherConfigMountPath {
+		t.Errorf("got init container env: %v", env)
+	}
+
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "dispatcher-tree"},
+		Data: map[string]string{
+			"conf.d__enabled_vhosts__default.vhost":          "<VirtualHost *:80>\n</VirtualHost>\n",
+			"conf.d__dispatcher.conf":                        "DispatcherConfig conf.dispatcher.d/dispatcher.any\n",
+			"conf.dispatcher.d__dispatcher.any":              "/farms { }\n",
+			"conf.dispatcher.d__enabled_farms__default.farm": "/default { }\n",
+		},
+	})
+	err := SetDispatcherConfigFiles(client, pod, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "/usr/local/apache2/conf.d/enabled_vhosts/default.vhost,/usr/local/apache2/conf.dispatcher.d/dispatcher.any,/usr/local/apache2/conf.dispatcher.d/enabled_farms/default.farm"
+	for _, container := range pod.Spec.Containers {
+		for _, e := range container.Env {
+			if e.Name == envSideCarConfigFiles && e.Value != expected {
+				t.Errorf("got sidecar files: %v", e.Value)
+			}
+		}
+		if container.Name != "dev-sidecar" {
+			continue
+		}
+		mounted := []string{}
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == dispatcherConfigVolumeName && mount.ReadOnly && mount.MountPath == path.Join(DefaultDispatcherConfigMountPath, mount.SubPath) {
+				mounted = append(mounted, mount.MountPath)
+			}
+		}
+		if strings.Join(mounted, ",") != expected {
+			t.Errorf("got sidecar mounts: %v", container.VolumeMounts)
+		}
+	}
+}
+
+func TestNewPodDispatcherConfigFromLogs(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		ConfigFrom: &aemv1beta1.DispatcherConfigSource{ConfigMapName: "dispatcher-tree"},
+	}
+	pod := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	logs := path.Join(DefaultDispatcherConfigMountPath, "logs")
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "dev-dispatcher" {
+			continue
+		}
+		// the deepest mount containing the logs decides whether httpd can write them
+		var logsMount *v1.VolumeMount
+		for i, mount := range container.VolumeMounts {
+			if (logs == mount.MountPath || strings.HasPrefix(logs, mount.MountPath+"/")) &&
+				(logsMount == nil || len(mount.MountPath) > len(logsMount.MountPath)) {
+				logsMount = &container.VolumeMounts[i]
+			}
+		}
+		if logsMount == nil || logsMount.ReadOnly {
+			t.Errorf("%s is not writable: %v", logs, container.VolumeMounts)
+		}
+		return
+	}
+	t.Error("dispatcher container not found")
+}
+
+func TestCheckDispatcherFarms(t *testing.T) {
+	tree := map[string][]byte{
+		"conf.d/dispatcher.conf":                       []byte("DispatcherConfig conf.dispatcher.d/dispatcher.any\n"),
+		"conf.dispatcher.d/dispatcher.any":             []byte("/farms {\n  $include \"enabled_farms/*.farm\"\n}\n"),
+		"conf.dispatcher.d/enabled_farms/default.farm": []byte("/default {\n/filter { $include \"../filters/filters.any\" }\n}\n"),
+		"conf.dispatcher.d/filters/filters.any":        []byte("/0001 { /type \"deny\" /glob \"*\" }\n"),
+	}
+	err := checkDispatcherFarms(tree, DefaultDispatcherConfigMountPath)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tree["conf.dispatcher.d/filters/filters.any"] = []byte("/0001 { /type \"deny\" }\n")
+	err = checkDispatcherFarms(tree, DefaultDispatcherConfigMountPath)
+	if err == nil {
+		t.Errorf("expected error for an invalid included filter")
+	}
+
+	tree["conf.dispatcher.d/filters/filters.any"] = []byte("/0001 { /type \"deny\" /glob \"*\" \n")
+	err = checkDispatcherFarms(tree, DefaultDispatcherConfigMountPath)
+	if err == nil {
+		t.Errorf("expected error for a syntax error")
+	}
+}
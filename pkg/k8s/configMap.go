@@ -1,23 +1,39 @@
 package k8s
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
 
 	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 const (
+	// DispatcherConfigHashAnnotation holds the hash of the configuration a dispatcher pod was created with
+	DispatcherConfigHashAnnotation = "dispatcher-config-hash"
 	// DispatcherVirtualHostConfigKey bedrock.conf file
 	DispatcherVirtualHostConfigKey = "bedrock.conf"
 	// DispatcherPublishConfigKey publish_dispatcher.any file
 	DispatcherPublishConfigKey = "publish_dispatcher.any"
 )
 
-// SetUpConfigMaps creates the configMaps for the deployment
+// SetUpConfigMaps creates the configMaps for the deployment, the dispatcher configMap is not
+// generated when the deployment provides its own dispatcher configuration
 func SetUpConfigMaps(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) error {
+	if dispatcherConfigSource(deployment) != nil {
+		return nil
+	}
 	return newDispatcherConfigMap(client, deployment)
 }
 
@@ -30,11 +46,12 @@ func newDispatcherConfigMap(client kubernetes.Interface, deployment *aemv1beta1.
 		"deployment": deployment.Name,
 	}
 
+	data, err := dispatcherConfigMapData(deployment)
+	if err != nil {
+		return err
+	}
 	cmap := &v1.ConfigMap{
-		Data: map[string]string{
-			DispatcherVirtualHostConfigKey: DispatcherVirtualHostConfig,
-			DispatcherPublishConfigKey:     DispatcherPublishConfig,
-		},
+		Data: data,
 	}
 	cmap.SetName(name)
 	cmap.Labels = labels
@@ -43,21 +60,266 @@ func newDispatcherConfigMap(client kubernetes.Interface, deployment *aemv1beta1.
 	}
 
 	// create the configMap with the metadata and check if exists
-	_, err := client.CoreV1().ConfigMaps(deployment.Namespace).Create(cmap)
-	if err != nil && !errors.IsAlreadyExists(err) {
+	_, err = client.CoreV1().ConfigMaps(deployment.Namespace).Create(cmap)
+	if err == nil || !errors.IsAlreadyExists(err) {
 		return err
 	}
 
-	return nil
+	// the config is regenerated when the publishers are scaled or the spec changes,
+	// the running dispatchers are restarted by the operator to pick it up
+	current, err := client.CoreV1().ConfigMaps(deployment.Namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(current.Data, cmap.Data) {
+		return nil
+	}
+	current.Data = cmap.Data
+	_, err = client.CoreV1().ConfigMaps(deployment.Namespace).Update(current)
+	return err
+}
+
+// dispatcherConfigMapData returns the generated configuration files of the dispatchers
+func dispatcherConfigMapData(deployment *aemv1beta1.AEMDeployment) (map[string]string, error) {
+	config := newDispatcherConfig(deployment)
+	virtualHost, err := dispatcherVirtualHostConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	farm, err := dispatcherPublishConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		DispatcherVirtualHostConfigKey: virtualHost,
+		DispatcherPublishConfigKey:     farm,
+	}, nil
+}
+
+// DispatcherConfigHash returns the hash of the configuration of the dispatchers, either the generated
+// configuration or the user provided configuration tree. Dispatcher pods are annotated with the hash
+// they were created with so they are restarted when the configuration changes.
+func DispatcherConfigHash(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) (string, error) {
+	files := map[string][]byte{}
+	if source := dispatcherConfigSource(deployment); source != nil {
+		tree, err := loadDispatcherConfigTree(client, deployment.Namespace, source)
+		if err != nil {
+			return "", err
+		}
+		files = tree
+	} else {
+		data, err := dispatcherConfigMapData(deployment)
+		if err != nil {
+			return "", err
+		}
+		for name, content := range data {
+			files[name] = []byte(content)
+		}
+	}
+	// httpd reads the mounted certificate at startup, a renewed certificate restarts the dispatchers
+	if DispatcherTLSSecretName(deployment) != "" {
+		content, err := dispatcherTLSContent(client, deployment)
+		if err != nil {
+			return "", err
+		}
+		files[dispatcherTLSVolumeName] = content
+	}
+	// the cache volume is replaced by restarting the dispatchers
+	if cache := dispatcherCache(deployment); cache != nil {
+		spec, err := json.Marshal(cache)
+		if err != nil {
+			return "", err
+		}
+		files[DispatcherCacheVolumeName] = spec
+	}
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write(files[name])
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// dispatcherConfig is the data of the dispatcher templates
+type dispatcherConfig struct {
+	ServerName      string
+	ServerAliases   []string
+	VirtualHosts    []string
+	ClientHeaders   []string
+	Renders         []dispatcherRender
+	Filters         []aemv1beta1.DispatcherFilter
+	CacheRules      []aemv1beta1.DispatcherRule
+	InvalidateRules []aemv1beta1.DispatcherRule
+	AllowedClients  []aemv1beta1.DispatcherRule
+	TLS             *dispatcherTLSFiles
+	// InternalInvalidation rejects the flush requests forwarded by the ingress controllers
+	InternalInvalidation bool
+	// DenyReplication rejects the requests to the replication receiver of the publishers
+	DenyReplication bool
+}
+
+// dispatcherTLSFiles are the certificate and the key of the HTTPS virtual host
+type dispatcherTLSFiles struct {
+	CertificateFile string
+	KeyFile         string
+}
+
+// dispatcherRender is a render of the farm
+type dispatcherRender struct {
+	Host string
+	Port string
+}
+
+var dispatcherTemplateFuncs = template.FuncMap{
+	"inc":        func(i int) int { return i + 1 },
+	"ruleID":     func(i int) string { return fmt.Sprintf("%04d", i+1) },
+	"quote":      quoteDispatcherValue,
+	"filterRule": dispatcherFilterRule,
+}
+
+var (
+	dispatcherVirtualHostTemplate = template.Must(template.New(DispatcherVirtualHostConfigKey).Parse(DispatcherVirtualHostConfig + dispatcherVirtualHostBodyTemplate))
+	dispatcherPublishTemplate     = template.Must(template.New(DispatcherPublishConfigKey).Funcs(dispatcherTemplateFuncs).Parse(DispatcherPublishConfig + dispatcherRulesTemplate))
+)
+
+// newDispatcherConfig returns the data of the dispatcher templates, the defaults are used for the
+// values not set in the spec.dispatcher of the deployment
+func newDispatcherConfig(deployment *aemv1beta1.AEMDeployment) dispatcherConfig {
+	config := dispatcherConfig{
+		ServerName:    "bedrock.xumak.com",
+		ServerAliases: []string{"localhost"},
+		VirtualHosts:  []string{"*"},
+		ClientHeaders: []string{"*"},
+		Renders:       dispatcherRenders(deployment),
+	}
+	// the network policies only allow the instances of the deployment and the ingress controllers,
+	// the requests of the ingress controllers have the address of the client
+	config.InternalInvalidation = deployment.Spec.NetworkPolicy != nil && deployment.Spec.NetworkPolicy.Enabled
+	// the network policies let the dispatchers reach the port of the publishers the author replicates to
+	config.DenyReplication = config.InternalInvalidation
+	spec := deployment.Spec.Dispatcher
+	if spec == nil {
+		return config
+	}
+	if spec.ServerName != "" {
+		config.ServerName = spec.ServerName
+	}
+	if len(spec.ServerAliases) > 0 {
+		config.ServerAliases = spec.ServerAliases
+	}
+	if len(spec.VirtualHosts) > 0 {
+		config.VirtualHosts = spec.VirtualHosts
+	}
+	if len(spec.ClientHeaders) > 0 {
+		config.ClientHeaders = spec.ClientHeaders
+	}
+	config.Filters = spec.Filters
+	config.CacheRules = spec.CacheRules
+	config.InvalidateRules = spec.InvalidateRules
+	config.AllowedClients = spec.AllowedClients
+	if DispatcherTLSSecretName(deployment) != "" {
+		config.TLS = &dispatcherTLSFiles{
+			CertificateFile: path.Join(DispatcherTLSDir, v1.TLSCertKey),
+			KeyFile:         path.Join(DispatcherTLSDir, v1.TLSPrivateKeyKey),
+		}
+	}
+	return config
+}
+
+// dispatcherVirtualHostConfig returns the Apache virtual host of the dispatchers
+func dispatcherVirtualHostConfig(config dispatcherConfig) (string, error) {
+	var buf bytes.Buffer
+	err := dispatcherVirtualHostTemplate.Execute(&buf, config)
+	return buf.String(), err
+}
+
+// dispatcherPublishConfig returns the farm of the dispatchers
+func dispatcherPublishConfig(config dispatcherConfig) (string, error) {
+	var buf bytes.Buffer
+	err := dispatcherPublishTemplate.Execute(&buf, config)
+	return buf.String(), err
+}
+
+// dispatcherRenders returns the renders of the farm, every publisher is listed so requests fail
+// over to the other publishers when one is down. The publisher paired with each dispatcher (PUBLISH_IP)
+// is listed pairingWeight extra times so it receives most of the requests of its dispatcher.
+func dispatcherRenders(deployment *aemv1beta1.AEMDeployment) []dispatcherRender {
+	renders := []dispatcherRender{}
+	if deployment.Spec.Dispatcher != nil {
+		for i := 0; i < deployment.Spec.Dispatcher.PairingWeight; i++ {
+			renders = append(renders, dispatcherRender{Host: "${PUBLISH_IP}", Port: "${PUBLISH_PORT}"})
+		}
+	}
+	for i := 1; i <= deployment.Spec.Publishers.Replicas; i++ {
+		publishName := MakePodName(deployment.Name, AEMRunmodePublish, fmt.Sprintf("%03d", i))
+		renders = append(renders, dispatcherRender{Host: GetPodHost(publishName, deployment.Name, deployment.Namespace), Port: "4503"})
+	}
+	return renders
+}
+
+// quoteDispatcherValue quotes a value of the farm, values enclosed in single quotes are
+// regular expressions and are kept as is
+func quoteDispatcherValue(value string) string {
+	if len(value) > 1 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return value
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// dispatcherFilterRule returns the properties of a filter entry e.g. /type "allow" /url "/content*"
+func dispatcherFilterRule(filter aemv1beta1.DispatcherFilter) string {
+	rule := fmt.Sprintf("/type %q", filter.Type)
+	properties := []struct {
+		name  string
+		value string
+	}{
+		{"glob", filter.Glob},
+		{"method", filter.Method},
+		{"url", filter.URL},
+		{"query", filter.Query},
+		{"protocol", filter.Protocol},
+		{"path", filter.Path},
+		{"selectors", filter.Selectors},
+		{"extension", filter.Extension},
+		{"suffix", filter.Suffix},
+	}
+	for _, p := range properties {
+		if p.value != "" {
+			rule = rule + fmt.Sprintf(" /%s %s", p.name, quoteDispatcherValue(p.value))
+		}
+	}
+	return rule
 }
 
 const (
 	// DispatcherVirtualHostConfig defines the basic VirtualHost for dispatchers
+	// it is the template of the virtual host rendered with the spec.dispatcher of the deployment
 	DispatcherVirtualHostConfig = `
 <VirtualHost _default_:80>
+{{- template "virtualhost" .}}
+</VirtualHost>
+{{- if .TLS}}
+
+<VirtualHost _default_:443>
+	SSLEngine on
+	SSLCertificateFile {{.TLS.CertificateFile}}
+	SSLCertificateKeyFile {{.TLS.KeyFile}}
+{{- template "virtualhost" .}}
+</VirtualHost>
+{{- end}}`
+
+	// dispatcherVirtualHostBodyTemplate is the configuration shared by the HTTP and HTTPS virtual hosts
+	dispatcherVirtualHostBodyTemplate = `{{define "virtualhost"}}
 	ServerAdmin webmaster@localhost
-	ServerName bedrock.xumak.com
-	ServerAlias localhost
+	ServerName {{.ServerName}}{{range .ServerAliases}}
+	ServerAlias {{.}}{{end}}
 	DocumentRoot /var/www/default/htdocs
 	<Directory />
 		Options FollowSymLinks
@@ -80,10 +342,16 @@ const (
 	CustomLog ${APACHE_LOG_DIR}/access.log combined
 
 	ProxyRequests off
-	ProxyPreserveHost On
-</VirtualHost>`
+	ProxyPreserveHost On{{if .InternalInvalidation}}
+
+	<Location "/dispatcher/invalidate.cache">
+		<If "-n req('X-Forwarded-For')">
+			Require all denied
+		</If>
+	</Location>{{end}}{{end}}`
 
 	//DispatcherPublishConfig defines the basic config publish config for dispatchers
+	// it is the template of the farm rendered with the spec.dispatcher of the deployment
 	DispatcherPublishConfig = `
 # First farm entry
 /website
@@ -93,8 +361,8 @@ const (
 		{
 		# Forward all request headers that are end-to-end. If you want
 		# to forward a specific set of headers, you'll have to list
-		# them here.
-		"*"
+		# them here.{{range .ClientHeaders}}
+		{{quote .}}{{end}}
 		}
 
 	# Hostname globbing for farm selection (virtual domain addressing)
@@ -107,22 +375,23 @@ const (
 		#
 		#   www.company.com
 		#   intranet.*
-		#   myhost:8888/mysite
-		"*"
+		#   myhost:8888/mysite{{range .VirtualHosts}}
+		{{quote .}}{{end}}
 		}
   
 	# The load will be balanced among these render instances
 	/renders
 		{
-		/rend01
+{{- range $i, $r := .Renders}}
+		/rend{{printf "%02d" (inc $i)}}
 		  {
 		  # Hostname or IP of the render
-		  /hostname "${PUBLISH_IP}"
+		  /hostname "{{$r.Host}}"
 		  # Port of the render
-		  /port "${PUBLISH_PORT}"
+		  /port "{{$r.Port}}"
 		  # Connect timeout in milliseconds, 0 to wait indefinitely
 		  # /timeout "0"
-		  }
+		  }{{end}}
 		}
   
 	# The filter section defines the requests that should be handled by the dispatcher.
@@ -157,6 +426,9 @@ const (
 	#
 	/filter
 		{
+{{- if .Filters}}{{range $i, $f := .Filters}}
+		/{{ruleID $i}} { {{filterRule $f}} }{{end}}
+{{- else}}
 		# Deny everything first and then allow specific entries
 		/0001 { /type "deny" /glob "*" }
 
@@ -208,6 +480,10 @@ const (
 		# Allow clienlibs
 		/0098 { /type "allow" /url "/etc.clientlibs/*" }
 		/0099 { /type "allow" /url "/etc/*" }
+{{- end}}{{if .DenyReplication}}
+
+		# The replication receiver of the publishers is only used by the author
+		/9999 { /type "deny" /url "/bin/receive*" }{{end}}
 		}
   
 	# The cache section regulates what responses will be cached and where.
@@ -243,6 +519,8 @@ const (
 		# - Request has no "Authorization" header (unless allowAuthorized is 1)
 		/rules
 		  {
+{{- if .CacheRules}}{{template "rules" .CacheRules}}
+{{- else}}
 		  /0000
 				{
 				# the globbing pattern to be compared against the url
@@ -254,6 +532,7 @@ const (
 				/glob "*"
 				/type "allow"
 				}
+{{- end}}
 		  }
   
 		# The invalidate section defines the pages that are "invalidated" after
@@ -263,6 +542,8 @@ const (
 		# cache.
 		/invalidate
 		  {
+{{- if .InvalidateRules}}{{template "rules" .InvalidateRules}}
+{{- else}}
 		  /0000
 				{
 				/glob "*"
@@ -284,12 +565,15 @@ const (
 				/glob "*/analytics.sitecatalyst.js"
 				/type "allow"
 				}
+{{- end}}
 		  }
   
 		# The allowedClients section restricts the client IP addresses that are
 		# allowed to issue activation requests.
 		/allowedClients
 		  {
+{{- if .AllowedClients}}{{template "rules" .AllowedClients}}
+{{- else}}
 		  # Uncomment the following to restrict activation requests to originate
 		  # from "localhost" only.
 		  #
@@ -303,6 +587,7 @@ const (
 		  #  /glob "127.0.0.1"
 		  #  /type "allow"
 		  #  }
+{{- end}}
 		  }
   
 		# The ignoreUrlParams section contains query string parameter names that
@@ -414,4 +699,12 @@ const (
 	  #  }
   
 	  }`
+
+	// dispatcherRulesTemplate renders the glob rules of the cache, invalidate and allowedClients sections
+	dispatcherRulesTemplate = `{{define "rules"}}{{range $i, $r := .}}
+		  /{{ruleID $i}}
+				{
+				/glob {{quote $r.Glob}}
+				/type "{{$r.Type}}"
+				}{{end}}{{end}}`
 )
@@ -0,0 +1,173 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+)
+
+func TestDispatcherRenders(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Publishers.Replicas = 2
+	table := []struct {
+		pairingWeight int
+		hosts         []string
+	}{
+		{pairingWeight: 0, hosts: []string{"dev-publish-001.dev.demo", "dev-publish-002.dev.demo"}},
+		{pairingWeight: 2, hosts: []string{"${PUBLISH_IP}", "${PUBLISH_IP}", "dev-publish-001.dev.demo", "dev-publish-002.dev.demo"}},
+	}
+	for _, i := range table {
+		deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{PairingWeight: i.pairingWeight}
+		hosts := []string{}
+		for _, render := range dispatcherRenders(deployment) {
+			hosts = append(hosts, render.Host)
+		}
+		if strings.Join(hosts, ",") != strings.Join(i.hosts, ",") {
+			t.Errorf("got: %v exected: %v", hosts, i.hosts)
+		}
+	}
+}
+
+func TestDispatcherPublishConfig(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Publishers.Replicas = 3
+	config, err := dispatcherPublishConfig(newDispatcherConfig(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"/rend03",
+		`/hostname "dev-publish-003.dev.demo"`,
+		`/0001 { /type "deny" /glob "*" }`,
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("%s missing in the farm: %s", expected, config)
+		}
+	}
+
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		VirtualHosts: []string{"www.example.com"},
+		Filters: []aemv1beta1.DispatcherFilter{
+			{Type: aemv1beta1.DispatcherRuleDeny, Glob: "*"},
+			{Type: aemv1beta1.DispatcherRuleAllow, URL: "/content/*", Extension: "'(html|json)'"},
+		},
+		CacheRules: []aemv1beta1.DispatcherRule{{Glob: "*.html", Type: aemv1beta1.DispatcherRuleAllow}},
+	}
+	config, err = dispatcherPublishConfig(newDispatcherConfig(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`"www.example.com"`,
+		`/0001 { /type "deny" /glob "*" }`,
+		`/0002 { /type "allow" /url "/content/*" /extension '(html|json)' }`,
+		"/0001\n\t\t\t\t{\n\t\t\t\t/glob \"*.html\"\n\t\t\t\t/type \"allow\"\n\t\t\t\t}",
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("%s missing in the farm: %s", expected, config)
+		}
+	}
+	if strings.Contains(config, "/0099") {
+		t.Errorf("default filters not replaced: %s", config)
+	}
+}
+
+func TestDispatcherVirtualHostConfig(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		ServerName:    "www.example.com",
+		ServerAliases: []string{"example.com", "localhost"},
+	}
+	config, err := dispatcherVirtualHostConfig(newDispatcherConfig(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\tServerName www.example.com\n\tServerAlias example.com\n\tServerAlias localhost\n"
+	if !strings.Contains(config, expected) {
+		t.Errorf("got: %s exected: %s", config, expected)
+	}
+	if strings.Contains(config, "_default_:443") || strings.Contains(config, "invalidate.cache") {
+		t.Errorf("unexpected HTTPS virtual host or invalidation rule: %s", config)
+	}
+
+	deployment.Spec.Dispatcher.TLS = &aemv1beta1.DispatcherTLSSpec{SecretName: "www-tls"}
+	config, err = dispatcherVirtualHostConfig(newDispatcherConfig(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "<VirtualHost _default_:443>\n\tSSLEngine on\n\tSSLCertificateFile /usr/local/apache2/conf/tls/tls.crt\n" +
+		"\tSSLCertificateKeyFile /usr/local/apache2/conf/tls/tls.key\n\tServerAdmin"
+	if !strings.Contains(config, expected) || strings.Count(config, "ServerName www.example.com") != 2 {
+		t.Errorf("got: %s exected: %s", config, expected)
+	}
+
+	deployment.Spec.NetworkPolicy = &aemv1beta1.NetworkPolicySpec{Enabled: true}
+	config, err = dispatcherVirtualHostConfig(newDispatcherConfig(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(config, "<Location \"/dispatcher/invalidate.cache\">") != 2 {
+		t.Errorf("got: %s expected the invalidation rule in both virtual hosts", config)
+	}
+}
+
+func TestDispatcherFarmDenyReplication(t *testing.T) {
+	rule := `/9999 { /type "deny" /url "/bin/receive*" }`
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	for _, filters := range [][]aemv1beta1.DispatcherFilter{nil, {{Type: "allow", URL: "/bin/*"}}} {
+		deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{Filters: filters}
+		deployment.Spec.NetworkPolicy = nil
+		data, err := dispatcherConfigMapData(deployment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(data[DispatcherPublishConfigKey], rule) {
+			t.Errorf("unexpected replication rule without network policies: %s", data[DispatcherPublishConfigKey])
+		}
+		deployment.Spec.NetworkPolicy = &aemv1beta1.NetworkPolicySpec{Enabled: true}
+		data, err = dispatcherConfigMapData(deployment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(data[DispatcherPublishConfigKey], rule) {
+			t.Errorf("got: %s expected the replication rule", data[DispatcherPublishConfigKey])
+		}
+		err = validateDispatcherConfigMap(deployment)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDispatcherConfigHash(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Publishers.Replicas = 1
+	hash, err := DispatcherConfigHash(nil, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, _ := DispatcherConfigHash(nil, deployment)
+	if hash != same {
+		t.Errorf("got: %v exected: %v", same, hash)
+	}
+	deployment.Spec.Publishers.Replicas = 2
+	scaled, _ := DispatcherConfigHash(nil, deployment)
+	if hash == scaled {
+		t.Errorf("hash not changed when the publishers are scaled")
+	}
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		Cache: &aemv1beta1.DispatcherCacheSpec{Medium: aemv1beta1.DispatcherCacheMemory},
+	}
+	cache, _ := DispatcherConfigHash(nil, deployment)
+	if cache == scaled {
+		t.Errorf("hash not changed when the cache volume changes")
+	}
+}
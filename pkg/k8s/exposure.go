@@ -0,0 +1,241 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
+)
+
+// Exposure constants
+const (
+	DefaultIngressClass        = "contour"
+	DefaultHostTemplate        = "{{.Instance}}-{{.Namespace}}.{{.Domain}}"
+	ingressClassAnnotation     = "kubernetes.io/ingress.class"
+	forceSSLRedirectAnnotation = "ingress.kubernetes.io/force-ssl-redirect"
+	issuerAnnotation           = "cert-manager.io/issuer"
+	clusterIssuerAnnotation    = "cert-manager.io/cluster-issuer"
+	clusterIssuerKind          = "ClusterIssuer"
+	sourceRangeAnnotation      = "nginx.ingress.kubernetes.io/whitelist-source-range"
+	authTypeAnnotation         = "nginx.ingress.kubernetes.io/auth-type"
+	authSecretAnnotation       = "nginx.ingress.kubernetes.io/auth-secret"
+	authRealmAnnotation        = "nginx.ingress.kubernetes.io/auth-realm"
+	authURLAnnotation          = "nginx.ingress.kubernetes.io/auth-url"
+	authSigninAnnotation       = "nginx.ingress.kubernetes.io/auth-signin"
+)
+
+// InstanceExposurePolicy returns the exposure policy of the instances of a runmode, the publishers
+// are internal by default as they are reached through the dispatchers.
+func InstanceExposurePolicy(runmode string, deployment *aemv1beta1.AEMDeployment) aemv1beta1.ExposurePolicy {
+	policy := aemv1beta1.ExposurePolicy("")
+	if spec := deployment.Spec.Exposure; spec != nil {
+		switch {
+		case runmode == AEMRunmodeAuthor && spec.Author != nil:
+			policy = spec.Author.Policy
+		case runmode == AEMRunmodePublish && spec.Publish != nil:
+			policy = spec.Publish.Policy
+		case runmode == AEMRunmodeDispatcher && spec.Dispatcher != nil:
+			policy = spec.Dispatcher.Policy
+		}
+	}
+	if policy != "" {
+		return policy
+	}
+	if runmode == AEMRunmodePublish {
+		return aemv1beta1.ExposureInternal
+	}
+	return aemv1beta1.ExposurePublic
+}
+
+// authorAnnotations returns the ingress annotations restricting the access to the authors
+func authorAnnotations(author *aemv1beta1.AuthorExposure) map[string]string {
+	annotations := map[string]string{}
+	if author == nil {
+		return annotations
+	}
+	if len(author.AllowedSourceRanges) > 0 {
+		annotations[sourceRangeAnnotation] = strings.Join(author.AllowedSourceRanges, ",")
+	}
+	if author.Auth == nil {
+		return annotations
+	}
+	if author.Auth.BasicAuthSecretName != "" {
+		annotations[authTypeAnnotation] = "basic"
+		annotations[authSecretAnnotation] = author.Auth.BasicAuthSecretName
+		annotations[authRealmAnnotation] = "Authentication Required"
+	}
+	if proxy := author.Auth.OAuth2Proxy; proxy != nil {
+		url := strings.TrimSuffix(proxy.URL, "/")
+		annotations[authURLAnnotation] = url + "/oauth2/auth"
+		annotations[authSigninAnnotation] = url + "/oauth2/start?rd=$scheme://$host$escaped_request_uri"
+	}
+	return annotations
+}
+
+// ExposureDefaults are the operator settings used for the values not set in spec.exposure.
+type ExposureDefaults struct {
+	IngressClass  string
+	Annotations   map[string]string
+	HostTemplate  string
+	TLSSecretName string
+	Issuer        *aemv1beta1.IssuerReference
+	Mode          aemv1beta1.ExposureMode
+	Gateway       *aemv1beta1.GatewayReference
+}
+
+// exposureDefaults returns the exposure defaults of the operator configuration, the ingresses
+// are redirected to HTTPS when the configuration has no annotations.
+func exposureDefaults() ExposureDefaults {
+	exposure := config.Get().Exposure
+	defaults := ExposureDefaults{
+		IngressClass:  exposure.IngressClass,
+		HostTemplate:  exposure.HostTemplate,
+		TLSSecretName: exposure.TLSSecretName,
+		Annotations:   exposure.Annotations,
+		Issuer:        exposure.Issuer,
+		Mode:          exposure.Mode,
+		Gateway:       exposure.Gateway,
+	}
+	if defaults.Annotations == nil {
+		defaults.Annotations = map[string]string{forceSSLRedirectAnnotation: "true"}
+	}
+	return defaults
+}
+
+// hostTemplateData is the data of the host template
+type hostTemplateData struct {
+	Instance   string
+	Runmode    string
+	Deployment string
+	Namespace  string
+	Domain     string
+}
+
+// instanceExposure is the resolved exposure of an instance
+type instanceExposure struct {
+	Host          string
+	Class         string
+	Annotations   map[string]string
+	TLSSecretName string
+}
+
+// newInstanceExposure resolves the ingress settings of an instance from spec.exposure and the
+// defaults of the operator.
+func newInstanceExposure(instanceName, runmode string, deployment *aemv1beta1.AEMDeployment, defaults ExposureDefaults) (instanceExposure, error) {
+	spec := exposureSpec(deployment)
+	exposure := newIngressExposure(MakeIngressName(instanceName), spec, deployment.Namespace, defaults)
+
+	hostTemplate := firstNonEmpty(spec.HostTemplate, defaults.HostTemplate, DefaultHostTemplate)
+	host, err := executeHostTemplate(hostTemplate, hostTemplateData{
+		Instance:   instanceName,
+		Runmode:    runmode,
+		Deployment: deployment.Name,
+		Namespace:  deployment.Namespace,
+		Domain:     config.Get().ExternalDomain,
+	})
+	if err != nil {
+		return exposure, err
+	}
+	exposure.Host = host
+
+	if runmode == AEMRunmodeAuthor {
+		for key, value := range authorAnnotations(spec.Author) {
+			exposure.Annotations[key] = value
+		}
+	}
+	return exposure, nil
+}
+
+// newIngressExposure resolves the annotations and the TLS secret of an ingress, they are shared by
+// the ingresses of the instances and the site.
+func newIngressExposure(ingressName string, spec *aemv1beta1.ExposureSpec, ns string, defaults ExposureDefaults) instanceExposure {
+	exposure := instanceExposure{Class: ingressClass(spec, defaults), Annotations: map[string]string{}}
+	for key, value := range defaults.Annotations {
+		exposure.Annotations[key] = value
+	}
+	for key, value := range spec.Annotations {
+		exposure.Annotations[key] = value
+	}
+	for key, value := range exposure.Annotations {
+		if value == "" {
+			delete(exposure.Annotations, key)
+		}
+	}
+
+	// an issuer in the spec gets its own secrets instead of the secret of the operator
+	secretName, issuer := spec.TLSSecretName, spec.Issuer
+	if secretName == "" && issuer == nil {
+		secretName, issuer = defaults.TLSSecretName, defaults.Issuer
+	}
+	if issuer != nil {
+		if issuer.Kind == clusterIssuerKind {
+			exposure.Annotations[clusterIssuerAnnotation] = issuer.Name
+		} else {
+			exposure.Annotations[issuerAnnotation] = issuer.Name
+		}
+	}
+	switch {
+	case secretName != "":
+		exposure.TLSSecretName = secretName
+	case issuer != nil:
+		exposure.TLSSecretName = ingressName + "-tls"
+	default:
+		exposure.TLSSecretName = ns + "-public-tls"
+	}
+	return exposure
+}
+
+// ingressClass returns the ingress class of the ingresses of a deployment
+func ingressClass(spec *aemv1beta1.ExposureSpec, defaults ExposureDefaults) string {
+	return firstNonEmpty(spec.IngressClass, defaults.IngressClass, DefaultIngressClass)
+}
+
+// isNginxIngressClass reports whether an ingress class is served by ingress-nginx, the only
+// controller reading the nginx.ingress.kubernetes.io annotations e.g. "nginx" or "nginx-internal".
+func isNginxIngressClass(class string) bool {
+	return class == "nginx" || strings.HasPrefix(class, "nginx-") || strings.HasSuffix(class, "-nginx")
+}
+
+// isContourIngressClass reports whether an ingress class is served by contour e.g. "contour"
+func isContourIngressClass(class string) bool {
+	return class == DefaultIngressClass || strings.HasPrefix(class, DefaultIngressClass+"-")
+}
+
+// exposureMode returns the exposure mode of a deployment
+func exposureMode(deployment *aemv1beta1.AEMDeployment, defaults ExposureDefaults) aemv1beta1.ExposureMode {
+	return aemv1beta1.ExposureMode(firstNonEmpty(string(exposureSpec(deployment).Mode), string(defaults.Mode), string(aemv1beta1.ExposureModeIngress)))
+}
+
+// exposureSpec returns spec.exposure, an empty spec if not set
+func exposureSpec(deployment *aemv1beta1.AEMDeployment) *aemv1beta1.ExposureSpec {
+	if deployment.Spec.Exposure == nil {
+		return &aemv1beta1.ExposureSpec{}
+	}
+	return deployment.Spec.Exposure
+}
+
+// executeHostTemplate returns the host of an instance
+func executeHostTemplate(hostTemplate string, data hostTemplateData) (string, error) {
+	t, err := template.New("host").Option("missingkey=error").Parse(hostTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid host template: %v", err)
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid host template: %v", err)
+	}
+	return strings.ToLower(buf.String()), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewInstanceExposure(t *testing.T) {
+	cfg := config.Default()
+	cfg.ExternalDomain = "grid.example.com"
+	config.Set(cfg)
+	defer config.Set(nil)
+	operatorDefaults := ExposureDefaults{
+		Annotations: map[string]string{forceSSLRedirectAnnotation: "true"},
+	}
+	table := []struct {
+		exposure    *aemv1beta1.ExposureSpec
+		defaults    ExposureDefaults
+		host        string
+		class       string
+		annotations map[string]string
+		secret      string
+	}{
+		{
+			exposure:    nil,
+			defaults:    operatorDefaults,
+			host:        "dev-author-001-demo.grid.example.com",
+			class:       "contour",
+			annotations: map[string]string{forceSSLRedirectAnnotation: "true"},
+			secret:      "demo-public-tls",
+		},
+		{
+			exposure: &aemv1beta1.ExposureSpec{
+				IngressClass: "nginx",
+				HostTemplate: "{{.Runmode}}.{{.Deployment}}.example.com",
+				Annotations:  map[string]string{forceSSLRedirectAnnotation: "", "nginx.ingress.kubernetes.io/proxy-body-size": "100m"},
+				Issuer:       &aemv1beta1.IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"},
+			},
+			defaults:    ExposureDefaults{Annotations: operatorDefaults.Annotations, TLSSecretName: "wildcard-tls"},
+			host:        "author.dev.example.com",
+			class:       "nginx",
+			annotations: map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "100m", clusterIssuerAnnotation: "letsencrypt"},
+			secret:      "dev-author-001-ingress-tls",
+		},
+		{
+			exposure:    &aemv1beta1.ExposureSpec{TLSSecretName: "www-tls"},
+			defaults:    ExposureDefaults{IngressClass: "traefik", Issuer: &aemv1beta1.IssuerReference{Name: "internal-ca"}},
+			host:        "dev-author-001-demo.grid.example.com",
+			class:       "traefik",
+			annotations: map[string]string{},
+			secret:      "www-tls",
+		},
+		{
+			exposure:    nil,
+			defaults:    ExposureDefaults{Issuer: &aemv1beta1.IssuerReference{Name: "internal-ca"}},
+			host:        "dev-author-001-demo.grid.example.com",
+			class:       "contour",
+			annotations: map[string]string{issuerAnnotation: "internal-ca"},
+			secret:      "dev-author-001-ingress-tls",
+		},
+		{
+			exposure: &aemv1beta1.ExposureSpec{Author: &aemv1beta1.AuthorExposure{
+				AllowedSourceRanges: []string{"10.0.0.0/8", "203.0.113.7/32"},
+				Auth:                &aemv1beta1.IngressAuthSpec{OAuth2Proxy: &aemv1beta1.OAuth2ProxySpec{URL: "https://auth.example.com/"}},
+			}},
+			host:  "dev-author-001-demo.grid.example.com",
+			class: "contour",
+			annotations: map[string]string{
+				sourceRangeAnnotation: "10.0.0.0/8,203.0.113.7/32",
+				authURLAnnotation:     "https://auth.example.com/oauth2/auth",
+				authSigninAnnotation:  "https://auth.example.com/oauth2/start?rd=$scheme://$host$escaped_request_uri",
+			},
+			secret: "demo-public-tls",
+		},
+	}
+	for _, i := range table {
+		deployment := &aemv1beta1.AEMDeployment{}
+		deployment.Name = "dev"
+		deployment.Namespace = "demo"
+		deployment.Spec.Exposure = i.exposure
+		exposure, err := newInstanceExposure("dev-author-001", AEMRunmodeAuthor, deployment, i.defaults)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exposure.Host != i.host || exposure.Class != i.class || exposure.TLSSecretName != i.secret || !reflect.DeepEqual(exposure.Annotations, i.annotations) {
+			t.Errorf("got: %+v exected: %s %s %s %v", exposure, i.host, i.class, i.secret, i.annotations)
+		}
+	}
+}
+
+func TestExposeInstance(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	ingresses := NewIngressReconciler(dynamicClient, IngressResource)
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	table := []struct {
+		runmode string
+		policy  aemv1beta1.ExposurePolicy
+		service bool
+		ingress bool
+	}{
+		{runmode: AEMRunmodePublish, policy: "", service: true, ingress: false},
+		{runmode: AEMRunmodePublish, policy: aemv1beta1.ExposurePublic, service: true, ingress: true},
+		{runmode: AEMRunmodePublish, policy: aemv1beta1.ExposureInternal, service: true, ingress: false},
+		{runmode: AEMRunmodePublish, policy: aemv1beta1.ExposureNone, service: false, ingress: false},
+		{runmode: AEMRunmodeAuthor, policy: "", service: true, ingress: true},
+		{runmode: AEMRunmodeAuthor, policy: aemv1beta1.ExposureNone, service: false, ingress: false},
+	}
+	for _, i := range table {
+		deployment.Spec.Exposure = &aemv1beta1.ExposureSpec{
+			Author:  &aemv1beta1.AuthorExposure{Policy: i.policy},
+			Publish: &aemv1beta1.RunmodeExposure{Policy: i.policy},
+		}
+		name := "dev-" + i.runmode + "-001"
+		err := ExposeInstance(client, EndpointReconcilers{Ingresses: ingresses}, name, i.runmode, deployment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.CoreV1().Services("demo").Get(MakeServiceName(name), metav1.GetOptions{})
+		if (err == nil) != i.service {
+			t.Errorf("%s %q got service error: %v", i.runmode, i.policy, err)
+		}
+		_, err = dynamicClient.Resource(IngressResource).Namespace("demo").Get(MakeIngressName(name), metav1.GetOptions{})
+		if (err == nil) != i.ingress {
+			t.Errorf("%s %q got ingress error: %v", i.runmode, i.policy, err)
+		}
+	}
+}
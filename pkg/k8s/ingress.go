@@ -0,0 +1,328 @@
+package k8s
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// managedAnnotationsAnnotation lists the annotations set by the operator in an ingress, they are
+// removed when they are no longer in the exposure settings
+const managedAnnotationsAnnotation = "aem.xumak.io/managed-annotations"
+
+// legacyManagedAnnotations were set in every ingress by the operator before it listed the managed
+// annotations, they are managed in the ingresses without the list
+var legacyManagedAnnotations = []string{forceSSLRedirectAnnotation, ingressClassAnnotation}
+
+// Ingress resources in order of preference
+var (
+	IngressResource = schema.GroupVersionResource{
+		Group:    "networking.k8s.io",
+		Version:  "v1",
+		Resource: "ingresses",
+	}
+	NetworkingV1beta1IngressResource = schema.GroupVersionResource{
+		Group:    "networking.k8s.io",
+		Version:  "v1beta1",
+		Resource: "ingresses",
+	}
+	ExtensionsV1beta1IngressResource = schema.GroupVersionResource{
+		Group:    "extensions",
+		Version:  "v1beta1",
+		Resource: "ingresses",
+	}
+)
+
+// DetectIngressResource returns the preferred ingress resource served by the cluster.
+func DetectIngressResource(client discovery.DiscoveryInterface) (schema.GroupVersionResource, error) {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("error discovering the API groups: %v", err)
+	}
+	served := map[string]bool{}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+	for _, resource := range []schema.GroupVersionResource{IngressResource, NetworkingV1beta1IngressResource, ExtensionsV1beta1IngressResource} {
+		groupVersion := resource.GroupVersion().String()
+		if !served[groupVersion] {
+			continue
+		}
+		list, err := client.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			return schema.GroupVersionResource{}, fmt.Errorf("error discovering %s: %v", groupVersion, err)
+		}
+		for _, r := range list.APIResources {
+			if r.Name == resource.Resource {
+				return resource, nil
+			}
+		}
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("the cluster does not serve ingresses")
+}
+
+// Ingress represents an ingress routing the given hosts to a single service port,
+// independent of the ingress API version.
+type Ingress struct {
+	Name          string
+	Namespace     string
+	Labels        map[string]string
+	Annotations   map[string]string
+	Class         string
+	Hosts         []string
+	ServiceName   string
+	ServicePort   int
+	TLSSecretName string
+	Owner         *metav1.OwnerReference
+}
+
+// IngressReconciler keeps the ingresses in their desired state using the ingress resource
+// served by the cluster.
+type IngressReconciler struct {
+	reconciler
+}
+
+// NewIngressReconciler returns a reconciler for the given ingress resource.
+func NewIngressReconciler(client dynamic.Interface, resource schema.GroupVersionResource) *IngressReconciler {
+	return &IngressReconciler{reconciler{client: client, resource: resource}}
+}
+
+// Apply creates the ingress, an existing ingress is updated when its rules, labels or the
+// annotations set by the operator drifted from the desired state.
+func (r *IngressReconciler) Apply(ingress Ingress) error {
+	return r.apply(r.object(ingress))
+}
+
+// object returns the ingress in the schema of the reconciled resource
+func (r *IngressReconciler) object(ingress Ingress) *unstructured.Unstructured {
+	var backend map[string]interface{}
+	if r.resource == IngressResource {
+		backend = map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": ingress.ServiceName,
+				"port": map[string]interface{}{"number": int64(ingress.ServicePort)},
+			},
+		}
+	} else {
+		backend = map[string]interface{}{
+			"serviceName": ingress.ServiceName,
+			"servicePort": int64(ingress.ServicePort),
+		}
+	}
+	path := map[string]interface{}{"path": "/", "backend": backend}
+	// the path type is required since v1, older APIs default it
+	if r.resource == IngressResource {
+		path["pathType"] = "Prefix"
+	}
+	rules := []interface{}{}
+	hosts := []interface{}{}
+	for _, host := range ingress.Hosts {
+		rules = append(rules, map[string]interface{}{
+			"host": host,
+			"http": map[string]interface{}{"paths": []interface{}{path}},
+		})
+		hosts = append(hosts, host)
+	}
+	spec := map[string]interface{}{"rules": rules}
+	// v1 sets the class in the spec, the annotation is deprecated and rejected along with it
+	annotations := map[string]string{}
+	for key, value := range ingress.Annotations {
+		annotations[key] = value
+	}
+	if r.resource == IngressResource {
+		delete(annotations, ingressClassAnnotation)
+		if ingress.Class != "" {
+			spec["ingressClassName"] = ingress.Class
+		}
+	} else if ingress.Class != "" {
+		annotations[ingressClassAnnotation] = ingress.Class
+	}
+	if ingress.TLSSecretName != "" {
+		spec["tls"] = []interface{}{
+			map[string]interface{}{"hosts": hosts, "secretName": ingress.TLSSecretName},
+		}
+	}
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": r.resource.GroupVersion().String(),
+			"kind":       "Ingress",
+			"spec":       spec,
+		},
+	}
+	obj.SetName(ingress.Name)
+	obj.SetNamespace(ingress.Namespace)
+	obj.SetLabels(ingress.Labels)
+	keys := []string{}
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	annotations[managedAnnotationsAnnotation] = strings.Join(keys, ",")
+	obj.SetAnnotations(annotations)
+	if ingress.Owner != nil {
+		obj.SetOwnerReferences([]metav1.OwnerReference{*ingress.Owner})
+	}
+	return obj
+}
+
+// reconciler creates, updates and deletes the objects of a resource with the dynamic client.
+type reconciler struct {
+	client   dynamic.Interface
+	resource schema.GroupVersionResource
+}
+
+// apply creates the object, an existing object is updated when it drifted from the desired state.
+func (r *reconciler) apply(desired *unstructured.Unstructured) error {
+	cli := r.client.Resource(r.resource).Namespace(desired.GetNamespace())
+	_, err := cli.Create(desired, metav1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return err
+	}
+	current, err := cli.Get(desired.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !mergeObject(current, desired) {
+		return nil
+	}
+	_, err = cli.Update(current, metav1.UpdateOptions{})
+	return err
+}
+
+// Delete deletes an object, a missing object is not an error.
+func (r *reconciler) Delete(ns, name string) error {
+	err := r.client.Resource(r.resource).Namespace(ns).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting %s %s: %v", r.resource.Resource, name, err)
+	}
+	return nil
+}
+
+// Prune deletes the objects matching the selector that are not in keep e.g. the ingress of a
+// removed instance.
+func (r *reconciler) Prune(ns string, selector labels.Selector, keep []string) error {
+	list, err := r.client.Resource(r.resource).Namespace(ns).List(metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return err
+	}
+	kept := map[string]bool{}
+	for _, name := range keep {
+		kept[name] = true
+	}
+	for _, item := range list.Items {
+		if kept[item.GetName()] {
+			continue
+		}
+		err := r.Delete(ns, item.GetName())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeObject sets the desired spec, labels and annotations in the current object, the fields
+// defaulted by the API server and the labels and annotations set by others are kept. It returns
+// whether the current object changed.
+func mergeObject(current, desired *unstructured.Unstructured) bool {
+	changed := false
+	currentSpec, _, _ := unstructured.NestedMap(current.Object, "spec")
+	desiredSpec, _, _ := unstructured.NestedMap(desired.Object, "spec")
+	currentTLS, _, _ := unstructured.NestedSlice(current.Object, "spec", "tls")
+	desiredTLS, _, _ := unstructured.NestedSlice(desired.Object, "spec", "tls")
+	if !containsFields(currentSpec, desiredSpec) || len(currentTLS) != len(desiredTLS) {
+		current.Object["spec"] = desiredSpec
+		changed = true
+	}
+
+	currentLabels := current.GetLabels()
+	if currentLabels == nil {
+		currentLabels = map[string]string{}
+	}
+	for key, value := range desired.GetLabels() {
+		if currentLabels[key] != value {
+			currentLabels[key] = value
+			changed = true
+		}
+	}
+	current.SetLabels(currentLabels)
+
+	currentAnnotations := current.GetAnnotations()
+	if currentAnnotations == nil {
+		currentAnnotations = map[string]string{}
+	}
+	desiredAnnotations := desired.GetAnnotations()
+	managed := strings.Split(currentAnnotations[managedAnnotationsAnnotation], ",")
+	if _, listed := currentAnnotations[managedAnnotationsAnnotation]; !listed {
+		if _, ok := desiredAnnotations[managedAnnotationsAnnotation]; ok {
+			managed = legacyManagedAnnotations
+		}
+	}
+	// the class annotation takes precedence over the class of the spec in the ingress controllers
+	if _, ok := desiredSpec["ingressClassName"]; ok {
+		managed = append(managed, ingressClassAnnotation)
+	}
+	for _, key := range managed {
+		_, desiredKey := desiredAnnotations[key]
+		if _, ok := currentAnnotations[key]; key != "" && ok && !desiredKey {
+			delete(currentAnnotations, key)
+			changed = true
+		}
+	}
+	for key, value := range desiredAnnotations {
+		if currentAnnotations[key] != value {
+			currentAnnotations[key] = value
+			changed = true
+		}
+	}
+	current.SetAnnotations(currentAnnotations)
+
+	if len(current.GetOwnerReferences()) == 0 && len(desired.GetOwnerReferences()) > 0 {
+		current.SetOwnerReferences(desired.GetOwnerReferences())
+		changed = true
+	}
+	return changed
+}
+
+// containsFields returns whether every field of desired has the same value in current, lists
+// must have the same length.
+func containsFields(current, desired interface{}) bool {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		c, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range d {
+			if !containsFields(c[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		c, ok := current.([]interface{})
+		if !ok || len(c) != len(d) {
+			return false
+		}
+		for i := range d {
+			if !containsFields(c[i], d[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(current, desired)
+}
@@ -0,0 +1,205 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetectIngressResource(t *testing.T) {
+	ingresses := []metav1.APIResource{{Name: "ingresses"}}
+	cases := []struct {
+		resources []*metav1.APIResourceList
+		expected  schema.GroupVersionResource
+		err       bool
+	}{
+		{
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "extensions/v1beta1", APIResources: ingresses},
+				{GroupVersion: "networking.k8s.io/v1beta1", APIResources: ingresses},
+				{GroupVersion: "networking.k8s.io/v1", APIResources: ingresses},
+			},
+			expected: IngressResource,
+		},
+		{
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "extensions/v1beta1", APIResources: ingresses},
+				{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "networkpolicies"}}},
+			},
+			expected: ExtensionsV1beta1IngressResource,
+		},
+		{
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "services"}}},
+			},
+			err: true,
+		},
+	}
+	for i, c := range cases {
+		client := fake.NewSimpleClientset()
+		client.Discovery().(*fakediscovery.FakeDiscovery).Resources = c.resources
+		resource, err := DetectIngressResource(client.Discovery())
+		if (err != nil) != c.err {
+			t.Errorf("case %d got error: %v", i, err)
+		}
+		if resource != c.expected {
+			t.Errorf("case %d got: %v expected: %v", i, resource, c.expected)
+		}
+	}
+}
+
+func TestIngressReconcilerApply(t *testing.T) {
+	cli := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	ingress := Ingress{
+		Name:          "dev-author-ingress",
+		Namespace:     "demo",
+		Labels:        endpointLabels("dev", "dev-author"),
+		Annotations:   map[string]string{issuerAnnotation: "letsencrypt"},
+		Class:         "contour",
+		Hosts:         []string{"author.example.com"},
+		ServiceName:   "dev-author-controller-svc",
+		ServicePort:   80,
+		TLSSecretName: "dev-author-ingress-tls",
+	}
+	for _, resource := range []schema.GroupVersionResource{IngressResource, NetworkingV1beta1IngressResource} {
+		r := NewIngressReconciler(cli, resource)
+		err := r.Apply(ingress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ingresses := cli.Resource(resource).Namespace("demo")
+		current, _ := ingresses.Get(ingress.Name, metav1.GetOptions{})
+
+		// drift and annotations added by others
+		unstructured.SetNestedSlice(current.Object, []interface{}{}, "spec", "tls")
+		annotations := current.GetAnnotations()
+		annotations["kubectl.kubernetes.io/last-applied-configuration"] = "{}"
+		current.SetAnnotations(annotations)
+		ingresses.Update(current, metav1.UpdateOptions{})
+
+		ingress.Annotations = map[string]string{}
+		ingress.Class = "nginx"
+		ingress.Hosts = []string{"author.example.org"}
+		err = r.Apply(ingress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		current, _ = ingresses.Get(ingress.Name, metav1.GetOptions{})
+		annotations = current.GetAnnotations()
+		class, _, _ := unstructured.NestedString(current.Object, "spec", "ingressClassName")
+		if resource == IngressResource {
+			if _, ok := annotations[ingressClassAnnotation]; ok || class != "nginx" {
+				t.Errorf("%v got class: %q annotations: %v", resource, class, annotations)
+			}
+		} else if annotations[ingressClassAnnotation] != "nginx" || class != "" {
+			t.Errorf("%v got class: %q annotations: %v", resource, class, annotations)
+		}
+		if annotations["kubectl.kubernetes.io/last-applied-configuration"] != "{}" {
+			t.Errorf("%v got annotations: %v", resource, annotations)
+		}
+		if _, ok := annotations[issuerAnnotation]; ok {
+			t.Errorf("%v removed annotation kept: %v", resource, annotations)
+		}
+		rules, _, _ := unstructured.NestedSlice(current.Object, "spec", "rules")
+		tls, _, _ := unstructured.NestedSlice(current.Object, "spec", "tls")
+		if len(rules) != 1 || rules[0].(map[string]interface{})["host"] != "author.example.org" || len(tls) != 1 {
+			t.Errorf("%v got spec: %v", resource, current.Object["spec"])
+		}
+		cli.ClearActions()
+		err = r.Apply(ingress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, action := range cli.Actions() {
+			if action.GetVerb() == "update" {
+				t.Errorf("%v updated without changes", resource)
+			}
+		}
+	}
+}
+
+func TestIngressReconcilerApplyLegacy(t *testing.T) {
+	ingress := Ingress{
+		Name:        "dev-author-ingress",
+		Namespace:   "demo",
+		Annotations: map[string]string{},
+		Class:       "nginx",
+		Hosts:       []string{"author.example.com"},
+		ServiceName: "dev-author-controller-svc",
+		ServicePort: 80,
+	}
+	for _, resource := range []schema.GroupVersionResource{IngressResource, NetworkingV1beta1IngressResource} {
+		// an ingress created by the operator before it listed the managed annotations
+		legacy := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": resource.GroupVersion().String(),
+			"kind":       "Ingress",
+			"spec":       map[string]interface{}{},
+		}}
+		legacy.SetName(ingress.Name)
+		legacy.SetNamespace(ingress.Namespace)
+		legacy.SetAnnotations(map[string]string{
+			forceSSLRedirectAnnotation:                         "true",
+			ingressClassAnnotation:                             "contour",
+			"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		})
+		cli := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), legacy)
+		err := NewIngressReconciler(cli, resource).Apply(ingress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		current, _ := cli.Resource(resource).Namespace("demo").Get(ingress.Name, metav1.GetOptions{})
+		annotations := current.GetAnnotations()
+		class, _, _ := unstructured.NestedString(current.Object, "spec", "ingressClassName")
+		if resource == IngressResource {
+			if _, ok := annotations[ingressClassAnnotation]; ok || class != "nginx" {
+				t.Errorf("%v got class: %q annotations: %v", resource, class, annotations)
+			}
+		} else if annotations[ingressClassAnnotation] != "nginx" {
+			t.Errorf("%v got annotations: %v", resource, annotations)
+		}
+		if _, ok := annotations[forceSSLRedirectAnnotation]; ok || annotations["kubectl.kubernetes.io/last-applied-configuration"] != "{}" {
+			t.Errorf("%v got annotations: %v", resource, annotations)
+		}
+	}
+}
+
+func TestPruneExternalEndpoints(t *testing.T) {
+	cli := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	r := NewIngressReconciler(cli, IngressResource)
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	for _, name := range []string{"dev-author", "dev-publish-001", "dev-publish-002"} {
+		r.Apply(Ingress{Name: MakeIngressName(name), Namespace: "demo", Labels: endpointLabels("dev", name), Hosts: []string{name}})
+	}
+	r.Apply(Ingress{Name: "other", Namespace: "demo", Hosts: []string{"other"}})
+
+	err := PruneExternalEndpoints(EndpointReconcilers{Ingresses: r}, []string{"dev-author", "dev-publish-001"}, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := cli.Resource(IngressResource).Namespace("demo").List(metav1.ListOptions{})
+	names := []string{}
+	for _, item := range list.Items {
+		names = append(names, item.GetName())
+	}
+	if len(names) != 3 || !containsString(names, "other") || containsString(names, "dev-publish-002-ingress") {
+		t.Errorf("got ingresses: %v", names)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
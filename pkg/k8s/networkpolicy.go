@@ -0,0 +1,176 @@
+package k8s
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Network policy constants
+const (
+	// namespaceNameLabel is set by Kubernetes in every namespace since 1.21
+	namespaceNameLabel                = "kubernetes.io/metadata.name"
+	operatorAppLabel                  = "aem-operator"
+	defaultIngressControllerNamespace = "projectcontour"
+)
+
+// SyncNetworkPolicies creates or updates the network policies of every runmode of the deployment,
+// they are deleted when the network policies are disabled.
+func SyncNetworkPolicies(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) error {
+	enabled := deployment.Spec.NetworkPolicy != nil && deployment.Spec.NetworkPolicy.Enabled
+	policies := client.NetworkingV1().NetworkPolicies(deployment.Namespace)
+	for _, runmode := range []string{AEMRunmodeAuthor, AEMRunmodePublish, AEMRunmodeDispatcher} {
+		if !enabled {
+			err := policies.Delete(MakeNetworkPolicyName(deployment.Name, runmode), &metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		err := syncNetworkPolicy(client, newNetworkPolicy(runmode, deployment))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncNetworkPolicy(client kubernetes.Interface, policy *networkingv1.NetworkPolicy) error {
+	policies := client.NetworkingV1().NetworkPolicies(policy.Namespace)
+	current, err := policies.Get(policy.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = policies.Create(policy)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(current.Spec, policy.Spec) {
+		return nil
+	}
+	current.Spec = policy.Spec
+	_, err = policies.Update(current)
+	return err
+}
+
+// newNetworkPolicy returns the network policy of the instances of a runmode. The policies work at
+// the port level: the author replicates to the port of the publishers also used by the
+// dispatchers, and the publishers and the author flush the dispatchers on their HTTP port.
+func newNetworkPolicy(runmode string, deployment *aemv1beta1.AEMDeployment) *networkingv1.NetworkPolicy {
+	operator := []networkingv1.NetworkPolicyPeer{operatorPeer()}
+	controllers := deployment.Spec.NetworkPolicy.IngressControllers
+	if len(controllers) == 0 {
+		controllers = []networkingv1.NetworkPolicyPeer{defaultIngressControllerPeer()}
+	}
+	public := InstanceExposurePolicy(runmode, deployment) == aemv1beta1.ExposurePublic
+
+	rules := []networkingv1.NetworkPolicyIngressRule{}
+	switch runmode {
+	case AEMRunmodeAuthor:
+		rules = append(rules, ingressRule(operator, aemPort, jmxPort))
+		if public {
+			rules = append(rules, ingressRule(controllers, aemPort))
+		}
+	case AEMRunmodePublish:
+		tiers := []networkingv1.NetworkPolicyPeer{
+			runmodePeer(AEMRunmodeDispatcher, deployment),
+			runmodePeer(AEMRunmodeAuthor, deployment),
+		}
+		rules = append(rules, ingressRule(tiers, publishPort), ingressRule(operator, publishPort, jmxPort))
+		if public {
+			rules = append(rules, ingressRule(controllers, publishPort))
+		}
+	case AEMRunmodeDispatcher:
+		tiers := []networkingv1.NetworkPolicyPeer{
+			runmodePeer(AEMRunmodePublish, deployment),
+			runmodePeer(AEMRunmodeAuthor, deployment),
+		}
+		rules = append(rules,
+			ingressRule(tiers, dispatcherHTTPPort, dispatcherHTTPSPort),
+			ingressRule(operator, dispatcherHTTPPort, dispatcherHTTPSPort, sidecarPort))
+		// the site is exposed even when the dispatchers are not
+		if site := exposureSpec(deployment).Site; public || (site != nil && len(site.Hosts) > 0) {
+			rules = append(rules, ingressRule(controllers, dispatcherHTTPPort, dispatcherHTTPSPort))
+		}
+	}
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MakeNetworkPolicyName(deployment.Name, runmode),
+			Namespace: deployment.Namespace,
+			Labels: map[string]string{
+				"vendor":     VendorAdobe,
+				"app":        AppAEM,
+				"deployment": deployment.Name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *runmodePeer(runmode, deployment).PodSelector,
+			Ingress:     rules,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	if deployment.AsOwnerReference() != nil {
+		policy.OwnerReferences = append(policy.OwnerReferences, *deployment.AsOwnerReference())
+	}
+	return policy
+}
+
+func ingressRule(peers []networkingv1.NetworkPolicyPeer, ports ...int) networkingv1.NetworkPolicyIngressRule {
+	rule := networkingv1.NetworkPolicyIngressRule{From: peers}
+	for _, port := range ports {
+		protocol := v1.ProtocolTCP
+		number := intstr.FromInt(port)
+		rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &number})
+	}
+	return rule
+}
+
+// runmodePeer selects the instances of a runmode of the deployment
+func runmodePeer(runmode string, deployment *aemv1beta1.AEMDeployment) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app":        AppAEM,
+				"deployment": deployment.Name,
+				"runmode":    runmode,
+			},
+		},
+	}
+}
+
+// operatorPeer selects the pods of the operator, in the namespace set in OPERATOR_NAMESPACE or
+// in any namespace if not set
+func operatorPeer() networkingv1.NetworkPolicyPeer {
+	peer := networkingv1.NetworkPolicyPeer{
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": operatorAppLabel}},
+		NamespaceSelector: &metav1.LabelSelector{},
+	}
+	if ns := os.Getenv("OPERATOR_NAMESPACE"); ns != "" {
+		peer.NamespaceSelector.MatchLabels = map[string]string{namespaceNameLabel: ns}
+	}
+	return peer
+}
+
+// defaultIngressControllerPeer selects the pods of the ingress controller namespace of the
+// configuration, the namespace of contour if not set
+func defaultIngressControllerPeer() networkingv1.NetworkPolicyPeer {
+	ns := firstNonEmpty(config.Get().Exposure.IngressControllerNamespace, defaultIngressControllerNamespace)
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: ns}},
+	}
+}
+
+// MakeNetworkPolicyName returns a desired name of the network policy of a runmode
+func MakeNetworkPolicyName(deploymentName, runmode string) string {
+	return fmt.Sprintf("%s-%s-netpol", deploymentName, runmode)
+}
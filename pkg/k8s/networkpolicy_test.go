@@ -0,0 +1,107 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewNetworkPolicy(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Spec.NetworkPolicy = &aemv1beta1.NetworkPolicySpec{Enabled: true}
+	table := []struct {
+		runmode  string
+		exposure *aemv1beta1.ExposureSpec
+		// the runmodes and ports of every rule, "operator" and "controllers" for the other peers
+		rules [][]string
+	}{
+		{
+			runmode: AEMRunmodeAuthor,
+			rules:   [][]string{{"operator", "4502", "9010"}, {"controllers", "4502"}},
+		},
+		{
+			runmode:  AEMRunmodeAuthor,
+			exposure: &aemv1beta1.ExposureSpec{Author: &aemv1beta1.AuthorExposure{Policy: aemv1beta1.ExposureInternal}},
+			rules:    [][]string{{"operator", "4502", "9010"}},
+		},
+		{
+			runmode: AEMRunmodePublish,
+			rules:   [][]string{{"dispatcher", "author", "4503"}, {"operator", "4503", "9010"}},
+		},
+		{
+			runmode:  AEMRunmodeDispatcher,
+			exposure: &aemv1beta1.ExposureSpec{Dispatcher: &aemv1beta1.RunmodeExposure{Policy: aemv1beta1.ExposureNone}},
+			rules:    [][]string{{"publish", "author", "80", "443"}, {"operator", "80", "443", "9090"}},
+		},
+		{
+			runmode: AEMRunmodeDispatcher,
+			exposure: &aemv1beta1.ExposureSpec{
+				Dispatcher: &aemv1beta1.RunmodeExposure{Policy: aemv1beta1.ExposureNone},
+				Site:       &aemv1beta1.SiteExposure{Hosts: []string{"www.example.com"}},
+			},
+			rules: [][]string{{"publish", "author", "80", "443"}, {"operator", "80", "443", "9090"}, {"controllers", "80", "443"}},
+		},
+	}
+	for _, i := range table {
+		deployment.Spec.Exposure = i.exposure
+		policy := newNetworkPolicy(i.runmode, deployment)
+		if policy.Spec.PodSelector.MatchLabels["runmode"] != i.runmode {
+			t.Errorf("%s got selector: %v", i.runmode, policy.Spec.PodSelector)
+		}
+		rules := [][]string{}
+		for _, rule := range policy.Spec.Ingress {
+			described := []string{}
+			for _, peer := range rule.From {
+				described = append(described, describePeer(peer))
+			}
+			for _, port := range rule.Ports {
+				described = append(described, port.Port.String())
+			}
+			rules = append(rules, described)
+		}
+		if !reflect.DeepEqual(rules, i.rules) {
+			t.Errorf("%s got: %v exected: %v", i.runmode, rules, i.rules)
+		}
+	}
+}
+
+func TestSyncNetworkPolicies(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.NetworkPolicy = &aemv1beta1.NetworkPolicySpec{Enabled: true}
+	err := SyncNetworkPolicies(client, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, _ := client.NetworkingV1().NetworkPolicies("demo").List(metav1.ListOptions{})
+	if len(policies.Items) != 3 {
+		t.Errorf("got %d policies", len(policies.Items))
+	}
+
+	deployment.Spec.NetworkPolicy.Enabled = false
+	err = SyncNetworkPolicies(client, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, _ = client.NetworkingV1().NetworkPolicies("demo").List(metav1.ListOptions{})
+	if len(policies.Items) != 0 {
+		t.Errorf("got %d policies after disabling them", len(policies.Items))
+	}
+}
+
+func describePeer(peer networkingv1.NetworkPolicyPeer) string {
+	switch {
+	case peer.PodSelector != nil && peer.PodSelector.MatchLabels["app"] == operatorAppLabel:
+		return "operator"
+	case peer.PodSelector != nil:
+		return peer.PodSelector.MatchLabels["runmode"]
+	}
+	return "controllers"
+}
@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"fmt"
+	"reflect"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SyncPodDisruptionBudgets creates or updates the disruption budgets of every runmode of the deployment
+func SyncPodDisruptionBudgets(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) error {
+	for _, runmode := range []string{AEMRunmodeAuthor, AEMRunmodePublish, AEMRunmodeDispatcher} {
+		err := syncPodDisruptionBudget(client, newPodDisruptionBudget(runmode, deployment))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncPodDisruptionBudget(client kubernetes.Interface, pdb *policyv1beta1.PodDisruptionBudget) error {
+	pdbs := client.PolicyV1beta1().PodDisruptionBudgets(pdb.Namespace)
+	current, err := pdbs.Get(pdb.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = pdbs.Create(pdb)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(current.Spec.MinAvailable, pdb.Spec.MinAvailable) {
+		return nil
+	}
+	current.Spec.MinAvailable = pdb.Spec.MinAvailable
+	_, err = pdbs.Update(current)
+	if errors.IsInvalid(err) {
+		// the spec of a budget can not be updated before Kubernetes 1.15
+		err = pdbs.Delete(pdb.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = pdbs.Create(pdb)
+	}
+	return err
+}
+
+// newPodDisruptionBudget returns the disruption budget for the instances of a runmode
+func newPodDisruptionBudget(runmode string, deployment *aemv1beta1.AEMDeployment) *policyv1beta1.PodDisruptionBudget {
+	minAvailable := intstr.FromInt(minAvailable(runmode, deployment))
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MakePDBName(deployment.Name, runmode),
+			Namespace: deployment.Namespace,
+			Labels: map[string]string{
+				"vendor":     VendorAdobe,
+				"app":        AppAEM,
+				"deployment": deployment.Name,
+			},
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":        AppAEM,
+					"deployment": deployment.Name,
+					"runmode":    runmode,
+				},
+			},
+		},
+	}
+	if deployment.AsOwnerReference() != nil {
+		pdb.OwnerReferences = append(pdb.OwnerReferences, *deployment.AsOwnerReference())
+	}
+	return pdb
+}
+
+// minAvailable returns the number of instances of a runmode that must stay available during
+// voluntary disruptions, publishers and dispatchers are evicted one at a time and the author
+// can not be evicted unless the deployment is paused.
+func minAvailable(runmode string, deployment *aemv1beta1.AEMDeployment) int {
+	if runmode == AEMRunmodeAuthor {
+		if deployment.Spec.Paused {
+			return 0
+		}
+		return deployment.Spec.Authors.Replicas
+	}
+	replicas := deployment.Spec.InstanceSpecFor(runmode).Replicas
+	if replicas <= 1 {
+		return 0
+	}
+	return replicas - 1
+}
+
+// MakePDBName returns a desired name of the disruption budget of a runmode
+func MakePDBName(deploymentName, runmode string) string {
+	return fmt.Sprintf("%s-%s-pdb", deploymentName, runmode)
+}
@@ -0,0 +1,31 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+)
+
+func TestMinAvailable(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Spec.Authors.Replicas = 1
+	deployment.Spec.Publishers.Replicas = 3
+	deployment.Spec.Dispatchers.Replicas = 1
+	table := []struct {
+		runmode string
+		paused  bool
+		output  int
+	}{
+		{runmode: AEMRunmodeAuthor, output: 1},
+		{runmode: AEMRunmodeAuthor, paused: true, output: 0},
+		{runmode: AEMRunmodePublish, output: 2},
+		{runmode: AEMRunmodeDispatcher, output: 0},
+	}
+	for _, i := range table {
+		deployment.Spec.Paused = i.paused
+		got := minAvailable(i.runmode, deployment)
+		if got != i.output {
+			t.Errorf("%s got: %v exected: %v", i.runmode, got, i.output)
+		}
+	}
+}
@@ -2,12 +2,13 @@ package k8s
 
 import (
 	"fmt"
+	"path"
 	"strconv"
 	"strings"
 
 	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -21,17 +22,29 @@ const (
 	AEMRunmodeAuthor            = "author"
 	AEMRunmodeDispatcher        = "dispatcher"
 	AEMHealtcheckReadinessURL   = "/system/health?tags=shallow"
-	AEMHealtcheckLivenessURL    = "/system/health?tags=shallow"
+	AEMHealtcheckLivenessURL    = "/system/health?tags="
+	AEMHealtcheckDefaultTags    = "shallow"
 	DispatcherSideCarLiveness   = "/check/liveness"
 	AEMDispatcherHealtcheckURL  = "/"
 	EnvCQPort                   = "CQ_PORT"
 	EnvCQRunmode                = "CQ_RUNMODE"
-	gridRegistry                = ""
+	EnvCQJVMOpts                = "CQ_JVM_OPTS"
+	AEMCRXMountPath             = "/bin/crx-quickstart"
 	aemContainerImage           = "grid/aem-danta:6.3-1.0.5-jdk8"
 	aemDispatcherContainerImage = "grid/dispatcher:4.2.2"
 	sideCarDispatcherImage      = "grid/sidecar-check-state:0.0.1"
 	ConfigVolumeKeySites        = "config-volume-sites"
 	ConfigVolumeKeyFarm         = "config-volume-farm"
+	TopologyKeyHostname         = "kubernetes.io/hostname"
+	TopologyKeyZone             = "topology.kubernetes.io/zone"
+	envSideCarConfigFiles       = "SIDE_CAR_CONFIG_FILES"
+	dispatcherFarmsDir          = "/usr/local/apache2/conf/farms.d"
+	dispatcherSitesDir          = "/usr/local/apache2/sites-enabled"
+	dispatcherFarmsVolumeName   = "dispatcher-farms"
+	dispatcherSitesVolumeName   = "dispatcher-sites"
+	// SourceInstanceAnnotation holds the name of the instance the repository
+	// of a pod was copied from.
+	SourceInstanceAnnotation = "source-instance"
 )
 
 // NewPod creates a new AEMPod.
@@ -47,10 +60,11 @@ func NewPod(name, runmode string, deployment *aemv1beta1.AEMDeployment) *v1.Pod
 		containers []v1.Container
 		volumes    []v1.Volume
 	)
+	instance := deployment.Spec.InstanceSpecFor(runmode)
 
 	switch runmode {
 	case AEMRunmodeAuthor, AEMRunmodePublish:
-		containers = append(containers, aemContainer(runmode))
+		containers = append(containers, aemContainer(runmode, instance))
 		volumes = []v1.Volume{
 			{
 				Name: AEMCRXVolumeName,
@@ -61,8 +75,9 @@ func NewPod(name, runmode string, deployment *aemv1beta1.AEMDeployment) *v1.Pod
 				},
 			},
 		}
+		volumes = append(volumes, instance.Volumes...)
 	case AEMRunmodeDispatcher:
-		containers = append(containers, dispatcherContainer(name, deployment.Name, deployment.Namespace))
+		containers = append(containers, dispatcherContainer(name, deployment.Name, deployment.Namespace, deployment.Spec.Publishers.Replicas))
 		containers = append(containers, dispatcherSideCar(deployment.Name))
 		volumes = []v1.Volume{
 			{
@@ -101,8 +116,13 @@ func NewPod(name, runmode string, deployment *aemv1beta1.AEMDeployment) *v1.Pod
 					},
 				},
 			},
+			emptyDirVolume(dispatcherFarmsVolumeName),
+			emptyDirVolume(dispatcherSitesVolumeName),
 		}
 	}
+	for i := range containers {
+		containers[i].ImagePullPolicy = imagePullPolicy(deployment)
+	}
 	automountServiceAccount := false
 	pod := v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -116,24 +136,66 @@ func NewPod(name, runmode string, deployment *aemv1beta1.AEMDeployment) *v1.Pod
 			Hostname:                     name,
 			Subdomain:                    deployment.Name,
 			AutomountServiceAccountToken: &automountServiceAccount,
+			ImagePullSecrets:             imagePullSecrets(deployment),
+			NodeSelector:                 instance.NodeSelector,
+			Tolerations:                  instance.Tolerations,
+			Affinity:                     instance.Affinity,
+			TopologySpreadConstraints:    topologySpread(runmode, instance, deployment),
 		},
 	}
+	if source := SourceInstanceName(name, deployment); source != "" {
+		pod.Annotations[SourceInstanceAnnotation] = source
+	}
+	if runmode == AEMRunmodeDispatcher {
+		applyDispatcherConfigFrom(&pod, deployment)
+		applyDispatcherTLS(&pod, deployment)
+		applyDispatcherCache(&pod, deployment)
+	}
+	applySecurityProfile(&pod, runmode, deployment)
 	if deployment.AsOwnerReference() != nil {
 		pod.OwnerReferences = append(pod.OwnerReferences, *deployment.AsOwnerReference())
 	}
 	return &pod
 }
 
-func aemContainer(runmode string) v1.Container {
+// topologySpread returns the spread constraints of an instance, by default publishers
+// and dispatchers are spread across nodes and zones so a single failure does not take
+// down the whole tier, the constraints are soft so the pods are always scheduled.
+func topologySpread(runmode string, instance *aemv1beta1.InstanceSpec, deployment *aemv1beta1.AEMDeployment) []v1.TopologySpreadConstraint {
+	if len(instance.TopologySpreadConstraints) > 0 {
+		return instance.TopologySpreadConstraints
+	}
+	if runmode != AEMRunmodePublish && runmode != AEMRunmodeDispatcher {
+		return nil
+	}
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			"app":        AppAEM,
+			"deployment": deployment.Name,
+			"runmode":    runmode,
+		},
+	}
+	constraints := []v1.TopologySpreadConstraint{}
+	for _, key := range []string{TopologyKeyHostname, TopologyKeyZone} {
+		constraints = append(constraints, v1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       key,
+			WhenUnsatisfiable: v1.ScheduleAnyway,
+			LabelSelector:     selector,
+		})
+	}
+	return constraints
+}
+
+func aemContainer(runmode string, instance *aemv1beta1.InstanceSpec) v1.Container {
 	p := 4502
-	jmxPort := 9010
 	if runmode == AEMRunmodePublish {
 		p = 4503
 	}
+	size := getSizeProfile(instance.Type)
 	container := v1.Container{
-		Name:            fmt.Sprintf("adobe-aem-%s", runmode),
-		Image:           getFullImageURL(aemContainerImage),
-		ImagePullPolicy: v1.PullIfNotPresent,
+		Name:  fmt.Sprintf("adobe-aem-%s", runmode),
+		Image: getFullImageURL(aemContainerImage),
 		Ports: []v1.ContainerPort{
 			{
 				Name:          "aem",
@@ -145,62 +207,44 @@ func aemContainer(runmode string) v1.Container {
 				Protocol:      v1.ProtocolTCP,
 			},
 		},
-		ReadinessProbe: &v1.Probe{
-			Handler: v1.Handler{
-				HTTPGet: &v1.HTTPGetAction{
-					Path: AEMHealtcheckReadinessURL,
-					Port: intstr.FromInt(p),
-				},
-			},
-			InitialDelaySeconds: 100,
-			PeriodSeconds:       10,
-		},
-		// LivenessProbe: &v1.Probe{
-		// 	Handler: v1.Handler{
-		// 		HTTPGet: &v1.HTTPGetAction{
-		// 			Path: AEMHealtcheckLivenessURL,
-		// 			Port: intstr.FromInt(p),
-		// 		},
-		// 	},
-		// 	InitialDelaySeconds: 100,
-		// 	PeriodSeconds:       360,
-		// },
+		StartupProbe:   aemStartupProbe(p, size, instance.Probes),
+		ReadinessProbe: aemReadinessProbe(p, instance.Probes),
+		LivenessProbe:  aemLivenessProbe(p, instance.Probes),
 		Env: []v1.EnvVar{
 			v1.EnvVar{
 				Name:  EnvCQRunmode,
-				Value: runmode,
+				Value: strings.Join(append([]string{runmode}, instance.RunModes...), ","),
 			},
 			v1.EnvVar{
 				Name:  EnvCQPort,
 				Value: strconv.Itoa(p),
 			},
+			v1.EnvVar{
+				Name:  EnvCQJVMOpts,
+				Value: size.jvmOpts(instance.JVMOpts),
+			},
 		},
+		EnvFrom: instance.EnvFrom,
 		VolumeMounts: []v1.VolumeMount{
 			v1.VolumeMount{
 				Name:      AEMCRXVolumeName,
-				MountPath: "/bin/crx-quickstart",
-			},
-		},
-		Resources: v1.ResourceRequirements{
-			Requests: v1.ResourceList{
-				v1.ResourceMemory: resource.MustParse("2Gi"),
-			},
-			Limits: v1.ResourceList{
-				v1.ResourceMemory: resource.MustParse("4Gi"),
+				MountPath: AEMCRXMountPath,
 			},
 		},
+		Resources: size.resources(),
 	}
+	container.Env = append(container.Env, instance.Env...)
+	container.VolumeMounts = append(container.VolumeMounts, instance.VolumeMounts...)
 	return container
 }
 
-func dispatcherContainer(podName, deploymentName, ns string) v1.Container {
+func dispatcherContainer(podName, deploymentName, ns string, publishers int) v1.Container {
 	httpPort := 80
 	httpsPort := 443
-	publishHost := matchPublishHost(podName, deploymentName, ns)
+	publishHost := matchPublishHost(podName, deploymentName, ns, publishers)
 	container := v1.Container{
-		Name:            makeVolumeKey(deploymentName, "dispatcher"),
-		Image:           getFullImageURL(aemDispatcherContainerImage),
-		ImagePullPolicy: v1.PullIfNotPresent,
+		Name:  makeVolumeKey(deploymentName, "dispatcher"),
+		Image: getFullImageURL(aemDispatcherContainerImage),
 		Ports: []v1.ContainerPort{
 			{
 				Name:          "http",
@@ -233,14 +277,27 @@ func dispatcherContainer(podName, deploymentName, ns string) v1.Container {
 				Value: "4503",
 			},
 		},
+		// the files are mounted with subPath so the running dispatchers are not updated when
+		// the configMap changes, the operator restarts them one at a time instead. The empty
+		// directories under them hide the farms and sites bundled in the image.
 		VolumeMounts: []v1.VolumeMount{
+			v1.VolumeMount{
+				Name:      dispatcherFarmsVolumeName,
+				MountPath: dispatcherFarmsDir,
+			},
+			v1.VolumeMount{
+				Name:      dispatcherSitesVolumeName,
+				MountPath: dispatcherSitesDir,
+			},
 			v1.VolumeMount{
 				Name:      ConfigVolumeKeyFarm,
-				MountPath: "/usr/local/apache2/conf/farms.d",
+				MountPath: path.Join(dispatcherFarmsDir, DispatcherPublishConfigKey),
+				SubPath:   DispatcherPublishConfigKey,
 			},
 			v1.VolumeMount{
 				Name:      ConfigVolumeKeySites,
-				MountPath: "/usr/local/apache2/sites-enabled",
+				MountPath: path.Join(dispatcherSitesDir, DispatcherVirtualHostConfigKey),
+				SubPath:   DispatcherVirtualHostConfigKey,
 			},
 		},
 	}
@@ -251,9 +308,8 @@ func dispatcherContainer(podName, deploymentName, ns string) v1.Container {
 func dispatcherSideCar(deploymentName string) v1.Container {
 	httpPort := 9090
 	container := v1.Container{
-		Name:            makeVolumeKey(deploymentName, "sidecar"),
-		Image:           getFullImageURL(sideCarDispatcherImage),
-		ImagePullPolicy: v1.PullAlways,
+		Name:  makeVolumeKey(deploymentName, "sidecar"),
+		Image: getFullImageURL(sideCarDispatcherImage),
 		Ports: []v1.ContainerPort{
 			{
 				Name:          "http",
@@ -275,18 +331,20 @@ func dispatcherSideCar(deploymentName string) v1.Container {
 		Env: []v1.EnvVar{
 			v1.EnvVar{
 				// files that are cached inside de sidecar container
-				Name:  "SIDE_CAR_CONFIG_FILES",
+				Name:  envSideCarConfigFiles,
 				Value: "/usr/local/apache2/conf/farms.d/publish_dispatcher.any,/usr/local/apache2/sites-enabled/bedrock.conf",
 			},
 		},
 		VolumeMounts: []v1.VolumeMount{
 			v1.VolumeMount{
 				Name:      ConfigVolumeKeyFarm,
-				MountPath: "/usr/local/apache2/conf/farms.d",
+				MountPath: path.Join(dispatcherFarmsDir, DispatcherPublishConfigKey),
+				SubPath:   DispatcherPublishConfigKey,
 			},
 			v1.VolumeMount{
 				Name:      ConfigVolumeKeySites,
-				MountPath: "/usr/local/apache2/sites-enabled",
+				MountPath: path.Join(dispatcherSitesDir, DispatcherVirtualHostConfigKey),
+				SubPath:   DispatcherVirtualHostConfigKey,
 			},
 		},
 	}
@@ -295,14 +353,18 @@ func dispatcherSideCar(deploymentName string) v1.Container {
 
 // matchPublishHost returns the matched publish host base on the dispacher name
 // for example: adobe-example-aem-dispatcher-001 -> adobe-example-aem-publish-001.example-aem.demo.svc.cluster.local
-// if the dispatcherName is "" returns localhost
-func matchPublishHost(dispatcherName, deploymentName, ns string) string {
+// dispatchers beyond the number of publishers are paired again from the first publisher
+// if the dispatcherName is "" or there are no publishers returns localhost
+func matchPublishHost(dispatcherName, deploymentName, ns string, publishers int) string {
 	publishHost := "localhost"
 	segs := strings.Split(dispatcherName, "-")
-	if len(segs) == 1 {
+	if len(segs) == 1 || publishers <= 0 {
 		return publishHost
 	}
 	id := segs[len(segs)-1]
+	if n, err := strconv.Atoi(id); err == nil && n > 0 {
+		id = fmt.Sprintf("%03d", (n-1)%publishers+1)
+	}
 	publishName := MakePodName(deploymentName, "publish", id)
 	publishHost = GetPodHost(publishName, deploymentName, ns)
 	return publishHost
@@ -321,6 +383,32 @@ func GetPodHost(podName, serviceName, ns string) string {
 }
 
 // getFullImageURL returns the full URL for the given image e.g. registry/image
+// the image is returned as is if the operator has no registry configured
 func getFullImageURL(image string) string {
-	return fmt.Sprintf("%v/%v", gridRegistry, image)
+	registry := strings.TrimSuffix(config.Get().Registry, "/")
+	if registry == "" {
+		return image
+	}
+	return fmt.Sprintf("%v/%v", registry, image)
+}
+
+// imagePullPolicy returns the pull policy of the containers of the deployment
+func imagePullPolicy(deployment *aemv1beta1.AEMDeployment) v1.PullPolicy {
+	if deployment.Spec.ImagePullPolicy == "" {
+		return v1.PullIfNotPresent
+	}
+	return deployment.Spec.ImagePullPolicy
+}
+
+// imagePullSecrets returns the pull secrets of the deployment followed by the
+// pull secrets configured in the operator for its registry
+func imagePullSecrets(deployment *aemv1beta1.AEMDeployment) []v1.LocalObjectReference {
+	secrets := append([]v1.LocalObjectReference{}, deployment.Spec.ImagePullSecrets...)
+	for _, name := range config.Get().ImagePullSecrets {
+		secrets = append(secrets, v1.LocalObjectReference{Name: name})
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	return secrets
 }
@@ -3,6 +3,8 @@ package k8s
 import (
 	"testing"
 
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
 	"k8s.io/api/core/v1"
 )
 
@@ -16,7 +18,7 @@ func containsPort(ports []v1.ContainerPort, port int) bool {
 }
 
 func TestAEMContainer(t *testing.T) {
-	authorContainer := aemContainer(AEMRunmodeAuthor)
+	authorContainer := aemContainer(AEMRunmodeAuthor, &aemv1beta1.InstanceSpec{})
 
 	if !containsPort(authorContainer.Ports, 4502) {
 		t.Error("Should expose port 4502")
@@ -26,7 +28,7 @@ func TestAEMContainer(t *testing.T) {
 		t.Error("Should expose jmx port 9010")
 	}
 
-	publishContainer := aemContainer(AEMRunmodePublish)
+	publishContainer := aemContainer(AEMRunmodePublish, &aemv1beta1.InstanceSpec{})
 	if !containsPort(publishContainer.Ports, 4503) {
 		t.Error("Should expose port 4503")
 	}
@@ -38,7 +40,7 @@ func TestAEMContainer(t *testing.T) {
 }
 
 func TestDispatcherContainer(t *testing.T) {
-	dispatcherContainer := dispatcherContainer("exampleName", "example-deployment", "demo")
+	dispatcherContainer := dispatcherContainer("exampleName", "example-deployment", "demo", 2)
 	if !containsPort(dispatcherContainer.Ports, 80) {
 		t.Error("Should expose port 80")
 	}
@@ -48,6 +50,40 @@ func TestDispatcherContainer(t *testing.T) {
 	}
 }
 
+func TestNewPodDispatcherMounts(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	pod := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	volumes := map[string]v1.Volume{}
+	for _, volume := range pod.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	expected := map[string]v1.VolumeMount{
+		"/usr/local/apache2/conf/farms.d":                        {Name: dispatcherFarmsVolumeName},
+		"/usr/local/apache2/sites-enabled":                       {Name: dispatcherSitesVolumeName},
+		"/usr/local/apache2/conf/farms.d/publish_dispatcher.any": {Name: ConfigVolumeKeyFarm, SubPath: DispatcherPublishConfigKey},
+		"/usr/local/apache2/sites-enabled/bedrock.conf":          {Name: ConfigVolumeKeySites, SubPath: DispatcherVirtualHostConfigKey},
+	}
+	mounts := map[string]v1.VolumeMount{}
+	for _, mount := range pod.Spec.Containers[0].VolumeMounts {
+		mounts[mount.MountPath] = v1.VolumeMount{Name: mount.Name, SubPath: mount.SubPath}
+	}
+	for mountPath, mount := range expected {
+		if mounts[mountPath] != mount {
+			t.Errorf("got mount: %+v exected: %+v at %s", mounts[mountPath], mount, mountPath)
+		}
+		if _, ok := volumes[mount.Name]; !ok {
+			t.Errorf("volume %s not found", mount.Name)
+		}
+	}
+	// the directories hide the farms and sites of the image
+	for _, name := range []string{dispatcherFarmsVolumeName, dispatcherSitesVolumeName} {
+		if volumes[name].EmptyDir == nil {
+			t.Errorf("%s should be an empty directory", name)
+		}
+	}
+}
+
 func TestSideContainer(t *testing.T) {
 	sideCarContainer := dispatcherSideCar("example-deployment")
 	if !containsPort(sideCarContainer.Ports, 9090) {
@@ -60,14 +96,18 @@ func TestMatchPublishHost(t *testing.T) {
 	deployName := "example-aem"
 	table := []struct {
 		dispatcherName string
+		publishers     int
 		output         string
 	}{
-		{dispatcherName: "example-aem-dispatcher-001", output: "example-aem-publish-001.example-aem.demo"},
-		{dispatcherName: "", output: "localhost"},
+		{dispatcherName: "example-aem-dispatcher-001", publishers: 2, output: "example-aem-publish-001.example-aem.demo"},
+		{dispatcherName: "example-aem-dispatcher-002", publishers: 2, output: "example-aem-publish-002.example-aem.demo"},
+		{dispatcherName: "example-aem-dispatcher-003", publishers: 2, output: "example-aem-publish-001.example-aem.demo"},
+		{dispatcherName: "example-aem-dispatcher-001", publishers: 0, output: "localhost"},
+		{dispatcherName: "", publishers: 2, output: "localhost"},
 	}
 
 	for _, i := range table {
-		got := matchPublishHost(i.dispatcherName, deployName, ns)
+		got := matchPublishHost(i.dispatcherName, deployName, ns, i.publishers)
 		if got != i.output {
 			t.Errorf("got: %v exected: %v", got, i.output)
 		}
@@ -90,3 +130,123 @@ func TestGetPodHost(t *testing.T) {
 		}
 	}
 }
+
+func TestNewPodScheduling(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Spec.Publishers.NodeSelector = map[string]string{"tier": "publish"}
+	deployment.Spec.Publishers.Tolerations = []v1.Toleration{{Key: "aem", Operator: v1.TolerationOpExists}}
+
+	publish := NewPod("dev-publish-001", AEMRunmodePublish, deployment)
+	if publish.Spec.NodeSelector["tier"] != "publish" {
+		t.Error("Should use the node selector of the publishers")
+	}
+	if len(publish.Spec.Tolerations) != 1 {
+		t.Error("Should use the tolerations of the publishers")
+	}
+	if len(publish.Spec.TopologySpreadConstraints) != 2 {
+		t.Error("Should spread publishers across nodes and zones")
+	}
+
+	author := NewPod("dev-author-001", AEMRunmodeAuthor, deployment)
+	if author.Spec.NodeSelector != nil || author.Spec.TopologySpreadConstraints != nil {
+		t.Error("Should not constrain the author by default")
+	}
+
+	deployment.Spec.Dispatchers.TopologySpreadConstraints = []v1.TopologySpreadConstraint{{MaxSkew: 2, TopologyKey: TopologyKeyZone}}
+	dispatcher := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	if len(dispatcher.Spec.TopologySpreadConstraints) != 1 {
+		t.Error("Should replace the default spread")
+	}
+}
+
+func TestAEMContainerEnv(t *testing.T) {
+	instance := &aemv1beta1.InstanceSpec{
+		Type:     "large",
+		RunModes: []string{"stage", "nosamplecontent"},
+		JVMOpts:  "-XX:+UseG1GC",
+		Env:      []v1.EnvVar{{Name: "TZ", Value: "UTC"}},
+	}
+	container := aemContainer(AEMRunmodeAuthor, instance)
+	env := map[string]string{}
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	if env[EnvCQRunmode] != "author,stage,nosamplecontent" {
+		t.Errorf("got: %v exected: author,stage,nosamplecontent", env[EnvCQRunmode])
+	}
+	if env[EnvCQJVMOpts] != "-server -Xms8g -Xmx8g -Djava.awt.headless=true -XX:+UseG1GC" {
+		t.Errorf("Should derive the heap from the type, got: %v", env[EnvCQJVMOpts])
+	}
+	if env["TZ"] != "UTC" {
+		t.Error("Should add the extra environment variables")
+	}
+	if container.Resources.Limits.Memory().String() != "16Gi" {
+		t.Errorf("Should derive the memory from the type, got: %v", container.Resources.Limits.Memory())
+	}
+}
+
+func TestGetFullImageURL(t *testing.T) {
+	defer config.Set(nil)
+	table := []struct {
+		registry string
+		output   string
+	}{
+		{registry: "", output: "grid/dispatcher:4.2.2"},
+		{registry: "registry.example.com/", output: "registry.example.com/grid/dispatcher:4.2.2"},
+	}
+	for _, i := range table {
+		cfg := config.Default()
+		cfg.Registry = i.registry
+		config.Set(cfg)
+		got := getFullImageURL("grid/dispatcher:4.2.2")
+		if got != i.output {
+			t.Errorf("got: %v exected: %v", got, i.output)
+		}
+	}
+}
+
+func TestNewPodImagePull(t *testing.T) {
+	cfg := config.Default()
+	cfg.ImagePullSecrets = []string{"grid-registry"}
+	config.Set(cfg)
+	defer config.Set(nil)
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Spec.ImagePullSecrets = []v1.LocalObjectReference{{Name: "site-registry"}}
+	pod := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	if len(pod.Spec.ImagePullSecrets) != 2 {
+		t.Errorf("Should use the deployment and operator pull secrets, got: %v", pod.Spec.ImagePullSecrets)
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.ImagePullPolicy != v1.PullIfNotPresent {
+			t.Errorf("%s should default to IfNotPresent, got: %v", c.Name, c.ImagePullPolicy)
+		}
+	}
+}
+
+func TestAEMContainerProbes(t *testing.T) {
+	container := aemContainer(AEMRunmodePublish, &aemv1beta1.InstanceSpec{Type: "large"})
+	if container.StartupProbe == nil || container.StartupProbe.FailureThreshold != 180 {
+		t.Error("Should derive the startup probe from the type")
+	}
+	if container.LivenessProbe == nil || container.LivenessProbe.HTTPGet.Path != "/system/health?tags=shallow" {
+		t.Error("Should check the shallow health checks by default")
+	}
+
+	instance := &aemv1beta1.InstanceSpec{
+		Probes: &aemv1beta1.ProbesSpec{
+			HealthCheckTags: "shallow,system",
+			Liveness:        &aemv1beta1.ProbeSpec{PeriodSeconds: 60},
+		},
+	}
+	container = aemContainer(AEMRunmodePublish, instance)
+	if container.LivenessProbe.HTTPGet.Path != "/system/health?tags=shallow,system" {
+		t.Errorf("Should use the configured tags, got: %v", container.LivenessProbe.HTTPGet.Path)
+	}
+	if container.LivenessProbe.PeriodSeconds != 60 || container.LivenessProbe.FailureThreshold != 5 {
+		t.Error("Should override only the configured settings")
+	}
+	if container.LivenessProbe.HTTPGet.Port.IntValue() != 4503 {
+		t.Error("Should probe the publish port")
+	}
+}
@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// aemStartupProbe returns the startup probe of the AEM container, it gives the instance
+// time to start according to its type before the readiness and liveness probes run.
+func aemStartupProbe(port int, size sizeProfile, probes *aemv1beta1.ProbesSpec) *v1.Probe {
+	probe := httpProbe(AEMHealtcheckReadinessURL, port)
+	probe.PeriodSeconds = 10
+	probe.TimeoutSeconds = 5
+	probe.FailureThreshold = size.startupFailureThreshold
+	if probes != nil {
+		tuneProbe(probe, probes.Startup)
+	}
+	return probe
+}
+
+// aemReadinessProbe returns the readiness probe of the AEM container
+func aemReadinessProbe(port int, probes *aemv1beta1.ProbesSpec) *v1.Probe {
+	probe := httpProbe(AEMHealtcheckReadinessURL, port)
+	probe.PeriodSeconds = 10
+	probe.TimeoutSeconds = 5
+	probe.FailureThreshold = 3
+	if probes != nil {
+		tuneProbe(probe, probes.Readiness)
+	}
+	return probe
+}
+
+// aemLivenessProbe returns the liveness probe of the AEM container, it runs the health checks
+// of the configured tags so a hung JVM is restarted.
+func aemLivenessProbe(port int, probes *aemv1beta1.ProbesSpec) *v1.Probe {
+	tags := AEMHealtcheckDefaultTags
+	if probes != nil && probes.HealthCheckTags != "" {
+		tags = probes.HealthCheckTags
+	}
+	probe := httpProbe(AEMHealtcheckLivenessURL+tags, port)
+	probe.PeriodSeconds = 30
+	probe.TimeoutSeconds = 10
+	probe.FailureThreshold = 5
+	if probes != nil {
+		tuneProbe(probe, probes.Liveness)
+	}
+	return probe
+}
+
+func httpProbe(path string, port int) *v1.Probe {
+	return &v1.Probe{
+		Handler: v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: path,
+				Port: intstr.FromInt(port),
+			},
+		},
+	}
+}
+
+// tuneProbe overrides the settings of the probe with the non zero values of the spec
+func tuneProbe(probe *v1.Probe, spec *aemv1beta1.ProbeSpec) {
+	if spec == nil {
+		return
+	}
+	if spec.InitialDelaySeconds > 0 {
+		probe.InitialDelaySeconds = spec.InitialDelaySeconds
+	}
+	if spec.PeriodSeconds > 0 {
+		probe.PeriodSeconds = spec.PeriodSeconds
+	}
+	if spec.TimeoutSeconds > 0 {
+		probe.TimeoutSeconds = spec.TimeoutSeconds
+	}
+	if spec.FailureThreshold > 0 {
+		probe.FailureThreshold = spec.FailureThreshold
+	}
+}
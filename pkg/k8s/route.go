@@ -0,0 +1,152 @@
+package k8s
+
+import (
+	"fmt"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const gatewayAPIGroup = "gateway.networking.k8s.io"
+
+// HTTPRoute resources in order of preference
+var (
+	HTTPRouteResource = schema.GroupVersionResource{
+		Group:    gatewayAPIGroup,
+		Version:  "v1",
+		Resource: "httproutes",
+	}
+	HTTPRouteV1beta1Resource = schema.GroupVersionResource{
+		Group:    gatewayAPIGroup,
+		Version:  "v1beta1",
+		Resource: "httproutes",
+	}
+)
+
+// DetectHTTPRouteResource returns the preferred HTTPRoute resource served by the cluster, false
+// when the Gateway API is not installed.
+func DetectHTTPRouteResource(client discovery.DiscoveryInterface) (schema.GroupVersionResource, bool, error) {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return schema.GroupVersionResource{}, false, fmt.Errorf("error discovering the API groups: %v", err)
+	}
+	served := map[string]bool{}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+	for _, resource := range []schema.GroupVersionResource{HTTPRouteResource, HTTPRouteV1beta1Resource} {
+		groupVersion := resource.GroupVersion().String()
+		if !served[groupVersion] {
+			continue
+		}
+		list, err := client.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			return schema.GroupVersionResource{}, false, fmt.Errorf("error discovering %s: %v", groupVersion, err)
+		}
+		for _, r := range list.APIResources {
+			if r.Name == resource.Resource {
+				return resource, true, nil
+			}
+		}
+	}
+	return schema.GroupVersionResource{}, false, nil
+}
+
+// HTTPRoute represents a route of the given hostnames to a single service port, attached to a
+// listener of a gateway.
+type HTTPRoute struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Hostnames   []string
+	ServiceName string
+	ServicePort int
+	Gateway     aemv1beta1.GatewayReference
+	Owner       *metav1.OwnerReference
+}
+
+// RouteReconciler keeps the HTTPRoutes in their desired state.
+type RouteReconciler struct {
+	reconciler
+}
+
+// NewRouteReconciler returns a reconciler for the given HTTPRoute resource.
+func NewRouteReconciler(client dynamic.Interface, resource schema.GroupVersionResource) *RouteReconciler {
+	return &RouteReconciler{reconciler{client: client, resource: resource}}
+}
+
+// Apply creates the route, an existing route is updated when it drifted from the desired state.
+func (r *RouteReconciler) Apply(route HTTPRoute) error {
+	return r.apply(r.object(route))
+}
+
+// object returns the route in the schema of the reconciled resource
+func (r *RouteReconciler) object(route HTTPRoute) *unstructured.Unstructured {
+	parentRef := map[string]interface{}{
+		"name":      route.Gateway.Name,
+		"namespace": firstNonEmpty(route.Gateway.Namespace, route.Namespace),
+	}
+	// the listener terminates TLS with its certificate
+	if route.Gateway.SectionName != "" {
+		parentRef["sectionName"] = route.Gateway.SectionName
+	}
+	hostnames := []interface{}{}
+	for _, hostname := range route.Hostnames {
+		hostnames = append(hostnames, hostname)
+	}
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": r.resource.GroupVersion().String(),
+			"kind":       "HTTPRoute",
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{parentRef},
+				"hostnames":  hostnames,
+				"rules": []interface{}{
+					map[string]interface{}{
+						"backendRefs": []interface{}{
+							map[string]interface{}{
+								"name": route.ServiceName,
+								"port": int64(route.ServicePort),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	obj.SetName(route.Name)
+	obj.SetNamespace(route.Namespace)
+	obj.SetLabels(route.Labels)
+	if route.Owner != nil {
+		obj.SetOwnerReferences([]metav1.OwnerReference{*route.Owner})
+	}
+	return obj
+}
+
+// gatewayReference returns the gateway the routes of a deployment are attached to
+func gatewayReference(deployment *aemv1beta1.AEMDeployment, defaults ExposureDefaults) (aemv1beta1.GatewayReference, error) {
+	gateway := exposureSpec(deployment).Gateway
+	if gateway == nil {
+		gateway = defaults.Gateway
+	}
+	if gateway == nil {
+		return aemv1beta1.GatewayReference{}, fmt.Errorf("no gateway for the GatewayAPI mode")
+	}
+	return *gateway, nil
+}
+
+// MakeRouteName returns a desired name of an HTTPRoute
+func MakeRouteName(podName string) string {
+	return fmt.Sprintf("%s-route", podName)
+}
+
+// MakeSiteRouteName returns the name of the HTTPRoute of the site hosts
+func MakeSiteRouteName(deploymentName string) string {
+	return fmt.Sprintf("%s-site-route", deploymentName)
+}
@@ -0,0 +1,86 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDetectHTTPRouteResource(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	discovery := client.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+	}
+	_, ok, err := DetectHTTPRouteResource(discovery)
+	if err != nil || ok {
+		t.Errorf("got: %v %v without the Gateway API", ok, err)
+	}
+	discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{
+		GroupVersion: "gateway.networking.k8s.io/v1beta1",
+		APIResources: []metav1.APIResource{{Name: "httproutes"}, {Name: "gateways"}},
+	})
+	resource, ok, err := DetectHTTPRouteResource(discovery)
+	if err != nil || !ok || resource != HTTPRouteV1beta1Resource {
+		t.Errorf("got: %v %v %v", resource, ok, err)
+	}
+}
+
+func TestExposeInstanceGatewayAPI(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	endpoints := EndpointReconcilers{
+		Ingresses: NewIngressReconciler(dynamicClient, IngressResource),
+		Routes:    NewRouteReconciler(dynamicClient, HTTPRouteResource),
+	}
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Exposure = &aemv1beta1.ExposureSpec{HostTemplate: "{{.Instance}}.example.com"}
+	name := "dev-author-001"
+	err := ExposeInstance(client, endpoints, name, AEMRunmodeAuthor, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment.Spec.Exposure.Mode = aemv1beta1.ExposureModeGatewayAPI
+	err = ExposeInstance(client, endpoints, name, AEMRunmodeAuthor, deployment)
+	if err == nil {
+		t.Errorf("expected error without a gateway")
+	}
+	deployment.Spec.Exposure.Gateway = &aemv1beta1.GatewayReference{Name: "public", Namespace: "gateways", SectionName: "https"}
+	err = ExposeInstance(client, endpoints, name, AEMRunmodeAuthor, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := dynamicClient.Resource(HTTPRouteResource).Namespace("demo").Get(MakeRouteName(name), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	parentRef := parentRefs[0].(map[string]interface{})
+	if parentRef["namespace"] != "gateways" || parentRef["sectionName"] != "https" || hostnames[0] != "dev-author-001.example.com" {
+		t.Errorf("got route: %v", route.Object)
+	}
+	_, err = dynamicClient.Resource(IngressResource).Namespace("demo").Get(MakeIngressName(name), metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("ingress kept in the GatewayAPI mode")
+	}
+
+	deployment.Spec.Exposure.Mode = aemv1beta1.ExposureModeIngress
+	err = ExposeInstance(client, endpoints, name, AEMRunmodeAuthor, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dynamicClient.Resource(HTTPRouteResource).Namespace("demo").Get(MakeRouteName(name), metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("route kept in the Ingress mode")
+	}
+}
@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+)
+
+// Security profile constants
+const (
+	defaultRunAsUser         int64 = 1000
+	defaultFSGroup           int64 = 1000
+	defaultSeccompProfile          = "runtime/default"
+	seccompPodAnnotation           = "seccomp.security.alpha.kubernetes.io/pod"
+	dispatcherLogsDir              = "/usr/local/apache2/logs"
+	tmpVolumeName                  = "tmp"
+	dispatcherLogsVolumeName       = "apache-logs"
+	unprivilegedPortSysctl         = "net.ipv4.ip_unprivileged_port_start"
+)
+
+// applySecurityProfile hardens the pod when the deployment requires it.
+// The dispatcher pods lower the first unprivileged port of their network namespace, so httpd binds
+// the ports 80 and 443 as the non root user of the pod without capabilities.
+func applySecurityProfile(pod *v1.Pod, runmode string, deployment *aemv1beta1.AEMDeployment) {
+	security := deployment.Spec.Security
+	if security == nil || !security.Hardened {
+		return
+	}
+	runAsNonRoot := true
+	pod.Spec.SecurityContext = &v1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+		RunAsUser:    int64OrDefault(security.RunAsUser, defaultRunAsUser),
+		RunAsGroup:   security.RunAsGroup,
+		FSGroup:      int64OrDefault(security.FSGroup, defaultFSGroup),
+	}
+	if runmode == AEMRunmodeDispatcher {
+		pod.Spec.SecurityContext.Sysctls = []v1.Sysctl{
+			{Name: unprivilegedPortSysctl, Value: "0"},
+		}
+	}
+	seccompProfile := security.SeccompProfile
+	if seccompProfile == "" {
+		seccompProfile = defaultSeccompProfile
+	}
+	pod.Annotations[seccompPodAnnotation] = seccompProfile
+
+	readOnly := runmode == AEMRunmodeDispatcher
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		container.SecurityContext = containerSecurityContext(readOnly)
+		if !readOnly {
+			continue
+		}
+		// writable paths of the read only containers
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      tmpVolumeName,
+			MountPath: "/tmp",
+		})
+		if container.Name == makeVolumeKey(deployment.Name, "dispatcher") {
+			// the cache volume is mounted by applyDispatcherCache
+			container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+				Name:      dispatcherLogsVolumeName,
+				MountPath: dispatcherLogsDir,
+			})
+		}
+	}
+	for i := range pod.Spec.InitContainers {
+		pod.Spec.InitContainers[i].SecurityContext = containerSecurityContext(readOnly)
+	}
+	if readOnly {
+		pod.Spec.Volumes = append(pod.Spec.Volumes,
+			emptyDirVolume(tmpVolumeName),
+			emptyDirVolume(dispatcherLogsVolumeName),
+		)
+	}
+}
+
+func containerSecurityContext(readOnlyRootFilesystem bool) *v1.SecurityContext {
+	allowPrivilegeEscalation := false
+	return &v1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		Capabilities: &v1.Capabilities{
+			Drop: []v1.Capability{"ALL"},
+		},
+	}
+}
+
+func emptyDirVolume(name string) v1.Volume {
+	return v1.Volume{
+		Name: name,
+		VolumeSource: v1.VolumeSource{
+			EmptyDir: &v1.EmptyDirVolumeSource{},
+		},
+	}
+}
+
+func int64OrDefault(value *int64, defaultValue int64) *int64 {
+	if value != nil {
+		return value
+	}
+	return &defaultValue
+}
@@ -0,0 +1,52 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+)
+
+func TestApplySecurityProfile(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	pod := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	if pod.Spec.SecurityContext != nil {
+		t.Error("Should not harden the pod by default")
+	}
+
+	deployment.Spec.Security = &aemv1beta1.SecuritySpec{Hardened: true}
+	pod = NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	if pod.Spec.SecurityContext == nil || !*pod.Spec.SecurityContext.RunAsNonRoot {
+		t.Fatal("Should run as non root")
+	}
+	if *pod.Spec.SecurityContext.FSGroup != defaultFSGroup {
+		t.Error("Should use the default fsGroup")
+	}
+	if pod.Annotations[seccompPodAnnotation] != defaultSeccompProfile {
+		t.Error("Should use the default seccomp profile")
+	}
+	sysctls := pod.Spec.SecurityContext.Sysctls
+	if len(sysctls) != 1 || sysctls[0].Name != unprivilegedPortSysctl || sysctls[0].Value != "0" {
+		t.Errorf("got dispatcher sysctls: %v", sysctls)
+	}
+	for _, c := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if !*c.SecurityContext.ReadOnlyRootFilesystem {
+			t.Errorf("%s should have a read only root filesystem", c.Name)
+		}
+		user := pod.Spec.SecurityContext.RunAsUser
+		if c.SecurityContext.RunAsUser != nil {
+			user = c.SecurityContext.RunAsUser
+		}
+		if *user == 0 || (c.SecurityContext.RunAsNonRoot != nil && !*c.SecurityContext.RunAsNonRoot) {
+			t.Errorf("%s should not run as root", c.Name)
+		}
+		if len(c.SecurityContext.Capabilities.Add) != 0 {
+			t.Errorf("%s got capabilities: %v", c.Name, c.SecurityContext.Capabilities.Add)
+		}
+	}
+
+	pod = NewPod("dev-author-001", AEMRunmodeAuthor, deployment)
+	if *pod.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem {
+		t.Error("Should not make the AEM root filesystem read only")
+	}
+}
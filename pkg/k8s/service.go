@@ -2,25 +2,55 @@ package k8s
 
 import (
 	"fmt"
-	"os"
+	"reflect"
 
 	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
 	"k8s.io/api/core/v1"
-	v1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 )
 
-// CreateServices handles service creation for deployment
+// Backend protocol annotations of the ingress controllers
+const (
+	// contourUpstreamTLSAnnotation lists the service ports contour connects to with TLS
+	contourUpstreamTLSAnnotation = "projectcontour.io/upstream-protocol.tls"
+	// nginxBackendProtocolAnnotation is the protocol ingress-nginx uses to reach the backend of an ingress
+	nginxBackendProtocolAnnotation = "nginx.ingress.kubernetes.io/backend-protocol"
+)
+
+// Ports of the instances
+const (
+	aemPort             = 4502
+	publishPort         = 4503
+	jmxPort             = 9010
+	dispatcherHTTPPort  = 80
+	dispatcherHTTPSPort = 443
+	sidecarPort         = 9090
+)
+
+// CreateServices creates or updates the services of the deployment: the discovery service of every
+// pod and the services of the authors and the publishers.
 func CreateServices(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) error {
-	return initialService(client, deployment)
+	err := initialService(client, deployment)
+	if err != nil {
+		return err
+	}
+	for _, runmode := range []string{AEMRunmodeAuthor, AEMRunmodePublish} {
+		err := syncRunmodeService(client, runmode, deployment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // initialService creates a new DNS-based service discovery to access all the pods
 // for more info see Kubernetes DNS-Based Service Discovery documentation
-// the entrypoints that match with the service are based on app: aem and deployment:<deployment-name>
+// the entrypoints that match with the service are based on app: aem and deployment:<deployment-name>,
+// the named ports publish SRV records e.g. _publish._tcp.<deployment-name>
 func initialService(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) error {
 	labels := map[string]string{
 		"vendor":     VendorAdobe,
@@ -36,14 +66,10 @@ func initialService(client kubernetes.Interface, deployment *aemv1beta1.AEMDeplo
 			ClusterIP:                "None",
 			PublishNotReadyAddresses: true,
 			Ports: []v1.ServicePort{
-				// This port is not necessary for the discovery, but is required when create a new service
-				v1.ServicePort{
-					Name: "http",
-					Port: 80,
-					TargetPort: intstr.IntOrString{
-						IntVal: 80,
-					},
-				},
+				servicePort(AEMRunmodeAuthor, aemPort),
+				servicePort(AEMRunmodePublish, publishPort),
+				servicePort("jmx", jmxPort),
+				servicePort("http", dispatcherHTTPPort),
 			},
 		},
 	}
@@ -53,25 +79,170 @@ func initialService(client kubernetes.Interface, deployment *aemv1beta1.AEMDeplo
 	if deployment.AsOwnerReference() != nil {
 		svc.OwnerReferences = append(svc.OwnerReferences, *deployment.AsOwnerReference())
 	}
-	_, err := client.CoreV1().Services(deployment.Namespace).Create(svc)
-	if err != nil && !errors.IsAlreadyExists(err) {
+	return createOrUpdateService(client, svc)
+}
+
+// syncRunmodeService creates or updates the service load balancing the ready instances of a runmode,
+// it is deleted when the runmode has no instances
+func syncRunmodeService(client kubernetes.Interface, runmode string, deployment *aemv1beta1.AEMDeployment) error {
+	name := MakeRunmodeServiceName(deployment.Name, runmode)
+	if deployment.Spec.InstanceSpecFor(runmode).Replicas == 0 {
+		err := client.CoreV1().Services(deployment.Namespace).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	port := aemPort
+	if runmode == AEMRunmodePublish {
+		port = publishPort
+	}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: deployment.Namespace,
+			Labels: map[string]string{
+				"vendor":     VendorAdobe,
+				"app":        AppAEM,
+				"deployment": deployment.Name,
+				"runmode":    runmode,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{
+				"app":        AppAEM,
+				"deployment": deployment.Name,
+				"runmode":    runmode,
+			},
+			Type:  v1.ServiceTypeClusterIP,
+			Ports: []v1.ServicePort{servicePort("aem", port), servicePort("jmx", jmxPort)},
+		},
+	}
+	if deployment.AsOwnerReference() != nil {
+		svc.OwnerReferences = append(svc.OwnerReferences, *deployment.AsOwnerReference())
+	}
+	return createOrUpdateService(client, svc)
+}
+
+// servicePort returns a TCP port of a service targeting the same port of the pods
+func servicePort(name string, port int) v1.ServicePort {
+	return v1.ServicePort{
+		Name:       name,
+		Protocol:   v1.ProtocolTCP,
+		Port:       int32(port),
+		TargetPort: intstr.FromInt(port),
+	}
+}
+
+// EndpointReconcilers reconcile the objects routing the external traffic to the instances, Routes
+// is nil when the cluster does not serve the Gateway API.
+type EndpointReconcilers struct {
+	Ingresses *IngressReconciler
+	Routes    *RouteReconciler
+}
+
+// Delete deletes the ingress and the route with the given names.
+func (e EndpointReconcilers) Delete(ns, ingressName, routeName string) error {
+	err := e.Ingresses.Delete(ns, ingressName)
+	if err != nil || e.Routes == nil {
 		return err
 	}
-	return nil
+	return e.Routes.Delete(ns, routeName)
 }
 
-// CreateExternalEndpoint exposes a single instance by creating a service and a ingress.
-func CreateExternalEndpoint(client kubernetes.Interface, instanceName, runmode string, deployment *aemv1beta1.AEMDeployment) error {
-	port := 4502
-	switch runmode {
-	case "publish":
-		port = 4503
-	case "dispatcher":
-		port = 80
+// apply creates or updates the ingress or the route of the exposure mode and deletes the other one
+func (e EndpointReconcilers) apply(mode aemv1beta1.ExposureMode, ingress Ingress, route HTTPRoute) error {
+	if mode != aemv1beta1.ExposureModeGatewayAPI {
+		err := e.Ingresses.Apply(ingress)
+		if err != nil || e.Routes == nil {
+			return err
+		}
+		return e.Routes.Delete(route.Namespace, route.Name)
 	}
+	if e.Routes == nil {
+		return fmt.Errorf("the cluster does not serve the Gateway API")
+	}
+	err := e.Routes.Apply(route)
+	if err != nil {
+		return err
+	}
+	return e.Ingresses.Delete(ingress.Namespace, ingress.Name)
+}
 
-	servicePort := 80
-	svcName := MakeServiceName(instanceName)
+// ExposeInstance creates, updates or deletes the service and the ingress or route of an instance
+// following the exposure policy of its runmode.
+func ExposeInstance(client kubernetes.Interface, endpoints EndpointReconcilers, instanceName, runmode string, deployment *aemv1beta1.AEMDeployment) error {
+	ns := deployment.Namespace
+	switch InstanceExposurePolicy(runmode, deployment) {
+	case aemv1beta1.ExposureNone:
+		err := endpoints.Delete(ns, MakeIngressName(instanceName), MakeRouteName(instanceName))
+		if err != nil {
+			return err
+		}
+		err = client.CoreV1().Services(ns).Delete(MakeServiceName(instanceName), &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	case aemv1beta1.ExposureInternal:
+		_, err := createInstanceService(client, instanceName, runmode, deployment)
+		if err != nil {
+			return err
+		}
+		return endpoints.Delete(ns, MakeIngressName(instanceName), MakeRouteName(instanceName))
+	}
+	return CreateExternalEndpoint(client, endpoints, instanceName, runmode, deployment)
+}
+
+// CreateExternalEndpoint exposes a single instance by creating or updating a service and an ingress,
+// or an HTTPRoute in the GatewayAPI mode.
+func CreateExternalEndpoint(client kubernetes.Interface, endpoints EndpointReconcilers, instanceName, runmode string, deployment *aemv1beta1.AEMDeployment) error {
+	servicePort, err := createInstanceService(client, instanceName, runmode, deployment)
+	if err != nil {
+		return err
+	}
+	defaults := exposureDefaults()
+	exposure, err := newInstanceExposure(instanceName, runmode, deployment, defaults)
+	if err != nil {
+		return err
+	}
+	mode := exposureMode(deployment, defaults)
+	route := HTTPRoute{
+		Name:        MakeRouteName(instanceName),
+		Namespace:   deployment.Namespace,
+		Labels:      endpointLabels(deployment.Name, instanceName),
+		Hostnames:   []string{exposure.Host},
+		ServiceName: MakeServiceName(instanceName),
+		ServicePort: servicePort,
+		Owner:       deployment.AsOwnerReference(),
+	}
+	if mode == aemv1beta1.ExposureModeGatewayAPI {
+		route.Gateway, err = gatewayReference(deployment, defaults)
+		if err != nil {
+			return err
+		}
+	}
+	if runmode == AEMRunmodeDispatcher {
+		exposure.Annotations = dispatcherIngressAnnotations(exposure.Annotations, deployment)
+	}
+	ingress := Ingress{
+		Name:          MakeIngressName(instanceName),
+		Namespace:     deployment.Namespace,
+		Labels:        endpointLabels(deployment.Name, instanceName),
+		Annotations:   exposure.Annotations,
+		Class:         exposure.Class,
+		Hosts:         []string{exposure.Host},
+		ServiceName:   MakeServiceName(instanceName),
+		ServicePort:   servicePort,
+		TLSSecretName: exposure.TLSSecretName,
+		Owner:         deployment.AsOwnerReference(),
+	}
+	return endpoints.apply(mode, ingress, route)
+}
+
+// createInstanceService creates or updates the service of an instance and returns its port
+func createInstanceService(client kubernetes.Interface, instanceName, runmode string, deployment *aemv1beta1.AEMDeployment) (int, error) {
+	port, annotations := instanceServicePort(runmode, deployment)
 	selector := map[string]string{
 		"vendor":     VendorAdobe,
 		"app":        AppAEM,
@@ -82,78 +253,141 @@ func CreateExternalEndpoint(client kubernetes.Interface, instanceName, runmode s
 
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      svcName,
-			Namespace: deployment.Namespace,
+			Name:        MakeServiceName(instanceName),
+			Namespace:   deployment.Namespace,
+			Annotations: annotations,
 		},
 		Spec: v1.ServiceSpec{
 			Selector: selector,
 			Type:     v1.ServiceTypeClusterIP,
-			Ports: []v1.ServicePort{
-				v1.ServicePort{
-					Name: "http",
-					Port: int32(servicePort),
-					TargetPort: intstr.IntOrString{
-						IntVal: int32(port),
-					},
-				},
-			},
+			Ports:    []v1.ServicePort{port},
 		},
 	}
 	if deployment.AsOwnerReference() != nil {
 		svc.OwnerReferences = append(svc.OwnerReferences, *deployment.AsOwnerReference())
 	}
-	_, err := client.CoreV1().Services(deployment.Namespace).Create(svc)
-	if err != nil && !errors.IsAlreadyExists(err) {
-		return err
+	return int(port.Port), createOrUpdateService(client, svc)
+}
+
+// instanceServicePort returns the port of the services in front of the instances of a runmode
+// and the annotations of those services
+func instanceServicePort(runmode string, deployment *aemv1beta1.AEMDeployment) (v1.ServicePort, map[string]string) {
+	port := 4502
+	switch runmode {
+	case "publish":
+		port = 4503
+	case "dispatcher":
+		port = 80
 	}
 
-	ingressHost := fmt.Sprintf("%s-%s.%s", instanceName, deployment.Namespace, os.Getenv("GRID_EXTERNAL_DOMAIN"))
-	ingress := &v1beta1.Ingress{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      MakeIngressName(instanceName),
-			Namespace: deployment.Namespace,
-			Annotations: map[string]string{
-				"ingress.kubernetes.io/force-ssl-redirect": "true",
-				"kubernetes.io/ingress.class":              "contour",
-			},
-		},
-		Spec: v1beta1.IngressSpec{
-			Rules: []v1beta1.IngressRule{
-				v1beta1.IngressRule{
-					Host: ingressHost,
-					IngressRuleValue: v1beta1.IngressRuleValue{
-						HTTP: &v1beta1.HTTPIngressRuleValue{
-							Paths: []v1beta1.HTTPIngressPath{
-								v1beta1.HTTPIngressPath{
-									Path: "/",
-									Backend: v1beta1.IngressBackend{
-										ServiceName: svcName,
-										ServicePort: intstr.FromInt(servicePort),
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			TLS: []v1beta1.IngressTLS{
-				v1beta1.IngressTLS{
-					Hosts: []string{
-						ingressHost,
-					},
-					SecretName: deployment.Namespace + "-public-tls",
-				},
-			},
+	servicePort := 80
+	portName := "http"
+	annotations := map[string]string{}
+	if runmode == AEMRunmodeDispatcher {
+		if serviceAnnotations, _, ok := dispatcherBackendTLS(deployment); ok {
+			port, servicePort, portName = 443, 443, "https"
+			annotations = serviceAnnotations
+		}
+	}
+	return v1.ServicePort{
+		Name:     portName,
+		Protocol: v1.ProtocolTCP,
+		Port:     int32(servicePort),
+		TargetPort: intstr.IntOrString{
+			IntVal: int32(port),
 		},
+	}, annotations
+}
+
+// dispatcherBackendTLS returns the service and ingress annotations making the ingress controller
+// connect to the dispatchers with TLS. It returns false when HTTPS is disabled or the annotations of
+// the ingress class are not known, the ingresses then keep using the HTTP port of the dispatchers.
+func dispatcherBackendTLS(deployment *aemv1beta1.AEMDeployment) (map[string]string, map[string]string, bool) {
+	if DispatcherTLSSecretName(deployment) == "" {
+		return nil, nil, false
 	}
-	if deployment.AsOwnerReference() != nil {
-		ingress.OwnerReferences = append(ingress.OwnerReferences, *deployment.AsOwnerReference())
+	class := ingressClass(exposureSpec(deployment), exposureDefaults())
+	switch {
+	case isContourIngressClass(class):
+		return map[string]string{contourUpstreamTLSAnnotation: "https"}, map[string]string{}, true
+	case isNginxIngressClass(class):
+		return map[string]string{}, map[string]string{nginxBackendProtocolAnnotation: "HTTPS"}, true
+	}
+	return nil, nil, false
+}
+
+// dispatcherIngressAnnotations returns the annotations of an ingress of the dispatchers, the
+// exposure annotations and the backend protocol of the ingress controller
+func dispatcherIngressAnnotations(annotations map[string]string, deployment *aemv1beta1.AEMDeployment) map[string]string {
+	_, ingressAnnotations, ok := dispatcherBackendTLS(deployment)
+	if !ok {
+		return annotations
+	}
+	for key, value := range ingressAnnotations {
+		annotations[key] = value
+	}
+	return annotations
+}
+
+// PruneExternalEndpoints deletes the ingresses and routes of the deployment whose instance is not in
+// instanceNames
+func PruneExternalEndpoints(endpoints EndpointReconcilers, instanceNames []string, deployment *aemv1beta1.AEMDeployment) error {
+	ingresses, routes := []string{}, []string{}
+	for _, name := range instanceNames {
+		ingresses = append(ingresses, MakeIngressName(name))
+		routes = append(routes, MakeRouteName(name))
 	}
-	_, err = client.ExtensionsV1beta1().Ingresses(deployment.Namespace).Create(ingress)
-	if err != nil && !errors.IsAlreadyExists(err) {
+	// the endpoints of the instances are labeled with their instance
+	selector, err := labels.Parse(fmt.Sprintf("app=%s,deployment=%s,instance", AppAEM, deployment.Name))
+	if err != nil {
 		return err
 	}
-	return nil
+	err = endpoints.Ingresses.Prune(deployment.Namespace, selector, ingresses)
+	if err != nil || endpoints.Routes == nil {
+		return err
+	}
+	return endpoints.Routes.Prune(deployment.Namespace, selector, routes)
+}
+
+// createOrUpdateService creates a service, an existing service is updated when its ports changed
+// e.g. HTTPS was enabled in the dispatchers
+func createOrUpdateService(client kubernetes.Interface, svc *v1.Service) error {
+	_, err := client.CoreV1().Services(svc.Namespace).Create(svc)
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return err
+	}
+	current, err := client.CoreV1().Services(svc.Namespace).Get(svc.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(current.Spec.Ports, svc.Spec.Ports) &&
+		current.Annotations[contourUpstreamTLSAnnotation] == svc.Annotations[contourUpstreamTLSAnnotation] {
+		return nil
+	}
+	current.Spec.Ports = svc.Spec.Ports
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+	delete(current.Annotations, contourUpstreamTLSAnnotation)
+	for key, value := range svc.Annotations {
+		current.Annotations[key] = value
+	}
+	_, err = client.CoreV1().Services(svc.Namespace).Update(current)
+	return err
+}
+
+func endpointLabels(deploymentName, instanceName string) map[string]string {
+	return map[string]string{
+		"vendor":     VendorAdobe,
+		"app":        AppAEM,
+		"deployment": deploymentName,
+		"instance":   instanceName,
+	}
+}
+
+// MakeRunmodeServiceName returns the name of the service of the instances of a runmode
+func MakeRunmodeServiceName(deploymentName, runmode string) string {
+	return fmt.Sprintf("%s-%s", deploymentName, runmode)
 }
 
 // MakeServiceName returns a desired name of a service
@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateServices(t *testing.T) {
+	// the discovery service of an existing deployment only has the placeholder port
+	client := fake.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "demo"},
+		Spec: v1.ServiceSpec{
+			ClusterIP: "None",
+			Ports:     []v1.ServicePort{{Name: "http", Port: 80}},
+		},
+	})
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Authors.Replicas = 1
+	deployment.Spec.Publishers.Replicas = 2
+	err := CreateServices(client, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := client.CoreV1().Services("demo").Get("dev", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := map[string]int32{}
+	for _, port := range svc.Spec.Ports {
+		ports[port.Name] = port.TargetPort.IntVal
+	}
+	if len(ports) != 4 || ports["author"] != 4502 || ports["publish"] != 4503 || ports["jmx"] != 9010 || ports["http"] != 80 {
+		t.Errorf("got ports: %v", svc.Spec.Ports)
+	}
+	publish, err := client.CoreV1().Services("demo").Get("dev-publish", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publish.Spec.Selector["runmode"] != AEMRunmodePublish || publish.Spec.Ports[0].Port != 4503 {
+		t.Errorf("got service: %+v", publish.Spec)
+	}
+
+	deployment.Spec.Publishers.Replicas = 0
+	err = CreateServices(client, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.CoreV1().Services("demo").Get("dev-publish", metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("publish service kept without publishers")
+	}
+	_, err = client.CoreV1().Services("demo").Get("dev-author", metav1.GetOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,114 @@
+package k8s
+
+import (
+	"fmt"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ExposeSite creates or updates the site service load balancing every ready dispatcher of the
+// deployment and the ingress or route of the site hosts. They are deleted when the deployment has
+// no dispatchers or no site hosts.
+func ExposeSite(client kubernetes.Interface, endpoints EndpointReconcilers, deployment *aemv1beta1.AEMDeployment) error {
+	ns := deployment.Namespace
+	site := exposureSpec(deployment).Site
+	if deployment.Spec.Dispatchers.Replicas == 0 {
+		err := endpoints.Delete(ns, MakeSiteIngressName(deployment.Name), MakeSiteRouteName(deployment.Name))
+		if err != nil {
+			return err
+		}
+		err = client.CoreV1().Services(ns).Delete(MakeSiteServiceName(deployment.Name), &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	port, annotations := instanceServicePort(AEMRunmodeDispatcher, deployment)
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        MakeSiteServiceName(deployment.Name),
+			Namespace:   ns,
+			Labels:      siteLabels(deployment.Name),
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			// the endpoints only include the ready dispatchers
+			Selector: map[string]string{
+				"app":        AppAEM,
+				"runmode":    AEMRunmodeDispatcher,
+				"deployment": deployment.Name,
+			},
+			Type:  v1.ServiceTypeClusterIP,
+			Ports: []v1.ServicePort{port},
+		},
+	}
+	if deployment.AsOwnerReference() != nil {
+		svc.OwnerReferences = append(svc.OwnerReferences, *deployment.AsOwnerReference())
+	}
+	err := createOrUpdateService(client, svc)
+	if err != nil {
+		return err
+	}
+
+	if site == nil || len(site.Hosts) == 0 {
+		return endpoints.Delete(ns, MakeSiteIngressName(deployment.Name), MakeSiteRouteName(deployment.Name))
+	}
+	defaults := exposureDefaults()
+	mode := exposureMode(deployment, defaults)
+	route := HTTPRoute{
+		Name:        MakeSiteRouteName(deployment.Name),
+		Namespace:   ns,
+		Labels:      siteLabels(deployment.Name),
+		Hostnames:   site.Hosts,
+		ServiceName: svc.Name,
+		ServicePort: int(port.Port),
+		Owner:       deployment.AsOwnerReference(),
+	}
+	if mode == aemv1beta1.ExposureModeGatewayAPI {
+		route.Gateway, err = gatewayReference(deployment, defaults)
+		if err != nil {
+			return err
+		}
+	}
+	exposure := newIngressExposure(MakeSiteIngressName(deployment.Name), exposureSpec(deployment), ns, defaults)
+	if site.TLSSecretName != "" {
+		exposure.TLSSecretName = site.TLSSecretName
+	}
+	ingress := Ingress{
+		Name:          MakeSiteIngressName(deployment.Name),
+		Namespace:     ns,
+		Labels:        siteLabels(deployment.Name),
+		Annotations:   dispatcherIngressAnnotations(exposure.Annotations, deployment),
+		Class:         exposure.Class,
+		Hosts:         site.Hosts,
+		ServiceName:   svc.Name,
+		ServicePort:   int(port.Port),
+		TLSSecretName: exposure.TLSSecretName,
+		Owner:         deployment.AsOwnerReference(),
+	}
+	return endpoints.apply(mode, ingress, route)
+}
+
+func siteLabels(deploymentName string) map[string]string {
+	return map[string]string{
+		"vendor":     VendorAdobe,
+		"app":        AppAEM,
+		"deployment": deploymentName,
+		"component":  "site",
+	}
+}
+
+// MakeSiteServiceName returns the name of the service in front of every dispatcher of a deployment
+func MakeSiteServiceName(deploymentName string) string {
+	return fmt.Sprintf("%s-site", deploymentName)
+}
+
+// MakeSiteIngressName returns the name of the ingress of the site hosts
+func MakeSiteIngressName(deploymentName string) string {
+	return fmt.Sprintf("%s-site-ingress", deploymentName)
+}
@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExposeSite(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	ingresses := NewIngressReconciler(dynamicClient, IngressResource)
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Dispatchers.Replicas = 2
+	deployment.Spec.Exposure = &aemv1beta1.ExposureSpec{
+		Issuer: &aemv1beta1.IssuerReference{Name: "letsencrypt"},
+		Site:   &aemv1beta1.SiteExposure{Hosts: []string{"www.example.com", "example.com"}},
+	}
+	err := ExposeSite(client, EndpointReconcilers{Ingresses: ingresses}, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := client.CoreV1().Services("demo").Get("dev-site", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Spec.Selector["runmode"] != AEMRunmodeDispatcher || svc.Spec.Selector["name"] != "" || svc.Spec.Ports[0].Port != 80 {
+		t.Errorf("got service: %+v", svc.Spec)
+	}
+	ingress, err := dynamicClient.Resource(IngressResource).Namespace("demo").Get("dev-site-ingress", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	tls, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "tls")
+	secret, _, _ := unstructured.NestedString(tls[0].(map[string]interface{}), "secretName")
+	if len(rules) != 2 || secret != "dev-site-ingress-tls" || ingress.GetAnnotations()[issuerAnnotation] != "letsencrypt" {
+		t.Errorf("got ingress: %v", ingress.Object)
+	}
+
+	// the site ingress is not an ingress of an instance
+	err = PruneExternalEndpoints(EndpointReconcilers{Ingresses: ingresses}, []string{}, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dynamicClient.Resource(IngressResource).Namespace("demo").Get("dev-site-ingress", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("site ingress pruned: %v", err)
+	}
+
+	deployment.Spec.Exposure.Site = nil
+	err = ExposeSite(client, EndpointReconcilers{Ingresses: ingresses}, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dynamicClient.Resource(IngressResource).Namespace("demo").Get("dev-site-ingress", metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("site ingress kept without hosts")
+	}
+
+	deployment.Spec.Dispatchers.Replicas = 0
+	err = ExposeSite(client, EndpointReconcilers{Ingresses: ingresses}, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.CoreV1().Services("demo").Get("dev-site", metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("site service kept without dispatchers")
+	}
+}
@@ -0,0 +1,64 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Instance types
+const (
+	InstanceTypeSmall  = "small"
+	InstanceTypeMedium = "medium"
+	InstanceTypeLarge  = "large"
+)
+
+// sizeProfile represents the resources assigned to an AEM instance of a type.
+type sizeProfile struct {
+	memoryRequest string
+	memoryLimit   string
+	// heap is the maximum JVM heap, the rest of the memory limit is left for
+	// the off heap memory used by the repository.
+	heap string
+	// startupFailureThreshold is the number of startup probe periods an
+	// instance has to start, larger repositories take longer to start.
+	startupFailureThreshold int32
+}
+
+var sizeProfiles = map[string]sizeProfile{
+	InstanceTypeSmall:  {memoryRequest: "2Gi", memoryLimit: "4Gi", heap: "2g", startupFailureThreshold: 60},
+	InstanceTypeMedium: {memoryRequest: "4Gi", memoryLimit: "8Gi", heap: "4g", startupFailureThreshold: 90},
+	InstanceTypeLarge:  {memoryRequest: "8Gi", memoryLimit: "16Gi", heap: "8g", startupFailureThreshold: 180},
+}
+
+// getSizeProfile returns the profile of an instance type, small is the default.
+func getSizeProfile(instanceType string) sizeProfile {
+	profile, ok := sizeProfiles[strings.ToLower(instanceType)]
+	if !ok {
+		return sizeProfiles[InstanceTypeSmall]
+	}
+	return profile
+}
+
+// resources returns the resources of the AEM container
+func (p sizeProfile) resources() v1.ResourceRequirements {
+	return v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceMemory: resource.MustParse(p.memoryRequest),
+		},
+		Limits: v1.ResourceList{
+			v1.ResourceMemory: resource.MustParse(p.memoryLimit),
+		},
+	}
+}
+
+// jvmOpts returns the JVM options of the AEM container followed by the extra options
+func (p sizeProfile) jvmOpts(extra string) string {
+	opts := fmt.Sprintf("-server -Xms%s -Xmx%s -Djava.awt.headless=true", p.heap, p.heap)
+	if extra != "" {
+		opts = opts + " " + extra
+	}
+	return opts
+}
@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"fmt"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// VolumeSnapshotResource is the CSI snapshot resource used for backups.
+var VolumeSnapshotResource = schema.GroupVersionResource{
+	Group:    snapshotAPIGroup,
+	Version:  "v1",
+	Resource: "volumesnapshots",
+}
+
+// VolumeSnapshot represents the fields of a CSI VolumeSnapshot used by the operator.
+type VolumeSnapshot struct {
+	Name         string
+	Instance     string
+	BackupID     string
+	ReadyToUse   bool
+	CreationTime metav1.Time
+}
+
+// CreateVolumeSnapshot creates a snapshot of the volume of an instance for the given backup.
+// Snapshots are not owned by the deployment so they can be restored after the deployment is deleted.
+func CreateVolumeSnapshot(cli dynamic.Interface, instanceName, backupID string, deployment *aemv1beta1.AEMDeployment) error {
+	pvcName := MakePVCName(instanceName)
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvcName,
+		},
+	}
+	if deployment.Spec.Backup.SnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = deployment.Spec.Backup.SnapshotClassName
+	}
+	snapshot := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": VolumeSnapshotResource.GroupVersion().String(),
+			"kind":       "VolumeSnapshot",
+			"spec":       spec,
+		},
+	}
+	snapshot.SetName(MakeSnapshotName(pvcName, backupID))
+	snapshot.SetNamespace(deployment.Namespace)
+	snapshot.SetLabels(snapshotLabels(deployment.Name, instanceName, backupID))
+	_, err := cli.Resource(VolumeSnapshotResource).Namespace(deployment.Namespace).Create(snapshot, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// ListVolumeSnapshots returns the snapshots taken for the deployment.
+func ListVolumeSnapshots(cli dynamic.Interface, deployment *aemv1beta1.AEMDeployment) ([]VolumeSnapshot, error) {
+	selector := labels.SelectorFromSet(map[string]string{
+		"app":        AppAEM,
+		"deployment": deployment.Name,
+	})
+	list, err := cli.Resource(VolumeSnapshotResource).Namespace(deployment.Namespace).List(metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []VolumeSnapshot{}
+	for _, item := range list.Items {
+		ready, _, _ := unstructured.NestedBool(item.Object, "status", "readyToUse")
+		snapshots = append(snapshots, VolumeSnapshot{
+			Name:         item.GetName(),
+			Instance:     item.GetLabels()["instance"],
+			BackupID:     item.GetLabels()["backup"],
+			ReadyToUse:   ready,
+			CreationTime: item.GetCreationTimestamp(),
+		})
+	}
+	return snapshots, nil
+}
+
+// DeleteVolumeSnapshot deletes a snapshot, a missing snapshot is not an error.
+func DeleteVolumeSnapshot(cli dynamic.Interface, ns, name string) error {
+	err := cli.Resource(VolumeSnapshotResource).Namespace(ns).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting snapshot %s: %v", name, err)
+	}
+	return nil
+}
+
+func snapshotLabels(deploymentName, instanceName, backupID string) map[string]string {
+	return map[string]string{
+		"app":        AppAEM,
+		"deployment": deploymentName,
+		"instance":   instanceName,
+		"backup":     backupID,
+	}
+}
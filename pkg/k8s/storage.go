@@ -3,9 +3,11 @@ package k8s
 import (
 	"fmt"
 	"path"
+	"strings"
 	"time"
 
 	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
 	"github.com/xumak-grid/aem-operator/pkg/retry"
 	"k8s.io/api/core/v1"
 	v1beta1storage "k8s.io/api/storage/v1beta1"
@@ -15,8 +17,6 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-var defaultStorageClass = "gp2"
-
 const (
 	storageClassPrefix    = "aem-backup"
 	backupPVVolName       = "aem-backup-storage"
@@ -27,11 +27,13 @@ const (
 	fromDirMountDir       = "/mnt/backup/from"
 	defaultVolumeSizeInMB = 1024 * 10 // 10 GiB
 
+	snapshotAPIGroup = "snapshot.storage.k8s.io"
 )
 
 // CreateAndWaitPVC creates a volume claim for an instance.
 func CreateAndWaitPVC(cli kubernetes.Interface, instanceName string, deployment *aemv1beta1.AEMDeployment) error {
 	name := MakePVCName(instanceName)
+	storageClass, size := claimStorage(instanceName, deployment)
 	claim := &v1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: name,
@@ -41,15 +43,16 @@ func CreateAndWaitPVC(cli kubernetes.Interface, instanceName string, deployment
 			},
 		},
 		Spec: v1.PersistentVolumeClaimSpec{
-			StorageClassName: &defaultStorageClass,
+			StorageClassName: storageClass,
 			AccessModes: []v1.PersistentVolumeAccessMode{
 				v1.ReadWriteOnce,
 			},
 			Resources: v1.ResourceRequirements{
 				Requests: v1.ResourceList{
-					v1.ResourceStorage: resource.MustParse(fmt.Sprintf("%dMi", defaultVolumeSizeInMB)),
+					v1.ResourceStorage: size,
 				},
 			},
+			DataSource: pvcDataSource(instanceName, deployment),
 		},
 	}
 	if deployment.AsOwnerReference() != nil {
@@ -59,6 +62,16 @@ func CreateAndWaitPVC(cli kubernetes.Interface, instanceName string, deployment
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return err
 	}
+	if err != nil {
+		// a replaced claim is deleted once its pod is gone, the new claim is created in a later sync
+		current, err := cli.CoreV1().PersistentVolumeClaims(deployment.Namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if current.DeletionTimestamp != nil {
+			return fmt.Errorf("PVC %s is being deleted", name)
+		}
+	}
 	// Wait until the Claim is Bound.
 	err = retry.Retry(500*time.Millisecond, 10, func() (bool, error) {
 		var err error
@@ -78,11 +91,116 @@ func CreateAndWaitPVC(cli kubernetes.Interface, instanceName string, deployment
 	return nil
 }
 
+// claimStorage returns the storage class and the size of the claim of an instance, the claim
+// of a dispatcher holds its cache with the PersistentVolume medium and is sized by the cache spec.
+func claimStorage(instanceName string, deployment *aemv1beta1.AEMDeployment) (*string, resource.Quantity) {
+	defaultStorageClass := config.Get().DefaultStorageClass
+	storageClass := &defaultStorageClass
+	size := resource.MustParse(fmt.Sprintf("%dMi", defaultVolumeSizeInMB))
+	cache := dispatcherCache(deployment)
+	if instanceRunmode(instanceName, deployment) != AEMRunmodeDispatcher ||
+		cache == nil || cache.Medium != aemv1beta1.DispatcherCachePersistentVolume {
+		return storageClass, size
+	}
+	if cache.StorageClassName != nil {
+		storageClass = cache.StorageClassName
+	}
+	if cache.SizeLimit != nil {
+		size = *cache.SizeLimit
+	}
+	return storageClass, size
+}
+
+// DeleteOutdatedDispatcherClaim deletes the claim of a dispatcher when its storage class or size
+// differ from the cache spec of the PersistentVolume medium, the claims can not be changed and the
+// cache is only lost with the dispatcher being restarted. It returns whether the claim was deleted.
+func DeleteOutdatedDispatcherClaim(cli kubernetes.Interface, instanceName string, deployment *aemv1beta1.AEMDeployment) (bool, error) {
+	cache := dispatcherCache(deployment)
+	if cache == nil || cache.Medium != aemv1beta1.DispatcherCachePersistentVolume {
+		return false, nil
+	}
+	name := MakePVCName(instanceName)
+	claim, err := cli.CoreV1().PersistentVolumeClaims(deployment.Namespace).Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	storageClass, size := claimStorage(instanceName, deployment)
+	currentSize := claim.Spec.Resources.Requests[v1.ResourceStorage]
+	if claim.Spec.StorageClassName != nil && *claim.Spec.StorageClassName == *storageClass && currentSize.Cmp(size) == 0 {
+		return false, nil
+	}
+	err = cli.CoreV1().PersistentVolumeClaims(deployment.Namespace).Delete(name, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// pvcDataSource returns the source the claim of an instance is populated from
+// when the deployment is a copy of an existing deployment, nil otherwise.
+func pvcDataSource(instanceName string, deployment *aemv1beta1.AEMDeployment) *v1.TypedLocalObjectReference {
+	source := SourceInstanceName(instanceName, deployment)
+	if source == "" {
+		return nil
+	}
+	if deployment.Spec.Source.BackupID != "" {
+		apiGroup := snapshotAPIGroup
+		return &v1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     "VolumeSnapshot",
+			Name:     MakeSnapshotName(MakePVCName(source), deployment.Spec.Source.BackupID),
+		}
+	}
+	return &v1.TypedLocalObjectReference{
+		Kind: "PersistentVolumeClaim",
+		Name: MakePVCName(source),
+	}
+}
+
+// SourceInstanceName returns the name of the instance in the source deployment
+// whose data is copied into the given instance, returns "" if the deployment
+// has no source or the instance is not copied.
+// example: qa-publish-002 with source stage -> stage-publish-002
+func SourceInstanceName(instanceName string, deployment *aemv1beta1.AEMDeployment) string {
+	source := deployment.Spec.Source
+	if source == nil || source.Deployment == "" {
+		return ""
+	}
+	suffix := strings.TrimPrefix(instanceName, deployment.Name+"-")
+	runmode := instanceRunmode(instanceName, deployment)
+	if runmode != AEMRunmodeAuthor && runmode != AEMRunmodePublish {
+		return ""
+	}
+	if source.Instance == "" {
+		return fmt.Sprintf("%s-%s", source.Deployment, suffix)
+	}
+	if strings.SplitN(source.Instance, "-", 2)[0] != runmode {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s", source.Deployment, source.Instance)
+}
+
+// instanceRunmode returns the runmode of an instance of the deployment
+// example: qa-publish-002 -> publish
+func instanceRunmode(instanceName string, deployment *aemv1beta1.AEMDeployment) string {
+	suffix := strings.TrimPrefix(instanceName, deployment.Name+"-")
+	return strings.SplitN(suffix, "-", 2)[0]
+}
+
 // MakePVCName returns a desired name of the persistent volume claim
 func MakePVCName(podName string) string {
 	return fmt.Sprintf("%s-pvc", podName)
 }
 
+// MakeSnapshotName returns a desired name of the volume snapshot of a claim
+// taken for the given backup
+func MakeSnapshotName(pvcName, backupID string) string {
+	return fmt.Sprintf("%s-%s", pvcName, backupID)
+}
+
 // makeVolumeKey returns deploymentName-key string
 func makeVolumeKey(deploymentName, key string) string {
 	return fmt.Sprintf("%s-%s", deploymentName, key)
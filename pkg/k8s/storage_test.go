@@ -1,6 +1,15 @@
 package k8s
 
-import "testing"
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
 
 func TestPVCName(t *testing.T) {
 	name := "aem-author-001"
@@ -9,3 +18,94 @@ func TestPVCName(t *testing.T) {
 		t.Error("Should be equal")
 	}
 }
+
+func TestSourceInstanceName(t *testing.T) {
+	table := []struct {
+		instance string
+		source   *aemv1beta1.SourceSpec
+		output   string
+	}{
+		{instance: "qa-publish-002", source: nil, output: ""},
+		{instance: "qa-publish-002", source: &aemv1beta1.SourceSpec{Deployment: "stage"}, output: "stage-publish-002"},
+		{instance: "qa-author-001", source: &aemv1beta1.SourceSpec{Deployment: "stage", Instance: "author-001"}, output: "stage-author-001"},
+		{instance: "qa-publish-001", source: &aemv1beta1.SourceSpec{Deployment: "stage", Instance: "author-001"}, output: ""},
+		{instance: "qa-dispatcher-001", source: &aemv1beta1.SourceSpec{Deployment: "stage"}, output: ""},
+	}
+	for _, i := range table {
+		deployment := &aemv1beta1.AEMDeployment{}
+		deployment.Name = "qa"
+		deployment.Spec.Source = i.source
+		got := SourceInstanceName(i.instance, deployment)
+		if got != i.output {
+			t.Errorf("got: %v exected: %v", got, i.output)
+		}
+	}
+}
+
+func TestClaimStorage(t *testing.T) {
+	size := resource.MustParse("50Gi")
+	storageClass := "io1"
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		Cache: &aemv1beta1.DispatcherCacheSpec{
+			Medium:           aemv1beta1.DispatcherCachePersistentVolume,
+			SizeLimit:        &size,
+			StorageClassName: &storageClass,
+		},
+	}
+	table := []struct {
+		instance     string
+		storageClass string
+		size         string
+	}{
+		{instance: "dev-author-001", storageClass: config.DefaultStorageClass, size: "10Gi"},
+		{instance: "dev-dispatcher-001", storageClass: "io1", size: "50Gi"},
+	}
+	for _, i := range table {
+		class, claimSize := claimStorage(i.instance, deployment)
+		if *class != i.storageClass || claimSize.Cmp(resource.MustParse(i.size)) != 0 {
+			t.Errorf("got: %s %s exected: %s %s for %s", *class, claimSize.String(), i.storageClass, i.size, i.instance)
+		}
+	}
+}
+
+func TestDeleteOutdatedDispatcherClaim(t *testing.T) {
+	defaultClass := config.DefaultStorageClass
+	claim := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: MakePVCName("dev-dispatcher-001"), Namespace: "demo"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &defaultClass,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+			},
+		},
+	}
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		Cache: &aemv1beta1.DispatcherCacheSpec{Medium: aemv1beta1.DispatcherCachePersistentVolume},
+	}
+	cli := fake.NewSimpleClientset(claim)
+	deleted, err := DeleteOutdatedDispatcherClaim(cli, "dev-dispatcher-001", deployment)
+	if err != nil || deleted {
+		t.Errorf("got: %v %v for a claim matching the cache spec", deleted, err)
+	}
+
+	size := resource.MustParse("50Gi")
+	deployment.Spec.Dispatcher.Cache.SizeLimit = &size
+	deleted, err = DeleteOutdatedDispatcherClaim(cli, "dev-dispatcher-001", deployment)
+	if err != nil || !deleted {
+		t.Errorf("got: %v %v for a claim smaller than the cache spec", deleted, err)
+	}
+
+	// a claim kept until its pod is gone is not reused
+	now := metav1.Now()
+	claim.DeletionTimestamp = &now
+	cli = fake.NewSimpleClientset(claim)
+	err = CreateAndWaitPVC(cli, "dev-dispatcher-001", deployment)
+	if err == nil {
+		t.Error("expected error for a claim being deleted")
+	}
+}
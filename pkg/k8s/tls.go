@@ -0,0 +1,186 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Dispatcher TLS constants
+const (
+	// DispatcherTLSDir is the directory of the certificate and the key in the dispatcher container
+	DispatcherTLSDir             = "/usr/local/apache2/conf/tls"
+	dispatcherTLSVolumeName      = "dispatcher-tls"
+	certManagerAPIGroup          = "cert-manager.io"
+	defaultCertificateIssuerKind = "Issuer"
+)
+
+// CertificateResource is the cert-manager resource used to request the certificate of the dispatchers.
+var CertificateResource = schema.GroupVersionResource{
+	Group:    certManagerAPIGroup,
+	Version:  "v1",
+	Resource: "certificates",
+}
+
+// dispatcherTLS returns the TLS configuration of the dispatchers, nil if HTTPS is disabled
+func dispatcherTLS(deployment *aemv1beta1.AEMDeployment) *aemv1beta1.DispatcherTLSSpec {
+	if deployment.Spec.Dispatcher == nil {
+		return nil
+	}
+	return deployment.Spec.Dispatcher.TLS
+}
+
+// DispatcherTLSSecretName returns the secret with the certificate of the dispatchers,
+// empty if HTTPS is disabled.
+func DispatcherTLSSecretName(deployment *aemv1beta1.AEMDeployment) string {
+	tls := dispatcherTLS(deployment)
+	if tls == nil {
+		return ""
+	}
+	if tls.SecretName == "" && tls.Certificate != nil {
+		return fmt.Sprintf("%s-dispatcher-tls", deployment.Name)
+	}
+	return tls.SecretName
+}
+
+// dispatcherTLSContent returns the name and the data of the secret with the certificate of the
+// dispatchers, only the name while the secret does not exist e.g. before cert-manager issues it.
+func dispatcherTLSContent(client kubernetes.Interface, deployment *aemv1beta1.AEMDeployment) ([]byte, error) {
+	secretName := DispatcherTLSSecretName(deployment)
+	secret, err := client.CoreV1().Secrets(deployment.Namespace).Get(secretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return []byte(secretName), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting the dispatcher TLS secret %s: %v", secretName, err)
+	}
+	keys := []string{}
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	buf.WriteString(secretName)
+	for _, key := range keys {
+		buf.WriteByte(0)
+		buf.WriteString(key)
+		buf.WriteByte(0)
+		buf.Write(secret.Data[key])
+	}
+	return buf.Bytes(), nil
+}
+
+// applyDispatcherTLS mounts the certificate of the dispatchers, the generated virtual host
+// reads the tls.crt and tls.key files of the secret.
+func applyDispatcherTLS(pod *v1.Pod, deployment *aemv1beta1.AEMDeployment) {
+	secretName := DispatcherTLSSecretName(deployment)
+	if secretName == "" {
+		return
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+		Name: dispatcherTLSVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: secretName},
+		},
+	})
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if container.Name != makeVolumeKey(deployment.Name, "dispatcher") {
+			continue
+		}
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      dispatcherTLSVolumeName,
+			MountPath: DispatcherTLSDir,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// SetUpDispatcherCertificate creates or updates the cert-manager Certificate of the dispatchers
+// when the deployment requests one, cert-manager writes the certificate to the TLS secret.
+func SetUpDispatcherCertificate(cli dynamic.Interface, deployment *aemv1beta1.AEMDeployment) error {
+	tls := dispatcherTLS(deployment)
+	if tls == nil || tls.Certificate == nil {
+		return nil
+	}
+	certificate := newDispatcherCertificate(deployment)
+	resource := cli.Resource(CertificateResource).Namespace(deployment.Namespace)
+	_, err := resource.Create(certificate, metav1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	current, err := resource.Get(certificate.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(current.Object["spec"], certificate.Object["spec"]) {
+		return nil
+	}
+	current.Object["spec"] = certificate.Object["spec"]
+	_, err = resource.Update(current, metav1.UpdateOptions{})
+	return err
+}
+
+// newDispatcherCertificate returns the cert-manager Certificate of the dispatchers
+func newDispatcherCertificate(deployment *aemv1beta1.AEMDeployment) *unstructured.Unstructured {
+	request := deployment.Spec.Dispatcher.TLS.Certificate
+	kind := request.IssuerKind
+	if kind == "" {
+		kind = defaultCertificateIssuerKind
+	}
+	dnsNames := []interface{}{}
+	for _, name := range certificateDNSNames(deployment) {
+		dnsNames = append(dnsNames, name)
+	}
+	certificate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": CertificateResource.GroupVersion().String(),
+			"kind":       "Certificate",
+			"spec": map[string]interface{}{
+				"secretName": DispatcherTLSSecretName(deployment),
+				"dnsNames":   dnsNames,
+				"issuerRef": map[string]interface{}{
+					"name":  request.IssuerName,
+					"kind":  kind,
+					"group": certManagerAPIGroup,
+				},
+			},
+		},
+	}
+	certificate.SetName(fmt.Sprintf("%s-dispatcher", deployment.Name))
+	certificate.SetNamespace(deployment.Namespace)
+	certificate.SetLabels(map[string]string{
+		"vendor":     VendorAdobe,
+		"app":        AppAEM,
+		"deployment": deployment.Name,
+	})
+	if deployment.AsOwnerReference() != nil {
+		certificate.SetOwnerReferences([]metav1.OwnerReference{*deployment.AsOwnerReference()})
+	}
+	return certificate
+}
+
+// certificateDNSNames returns the names of the requested certificate, by default the server
+// name and the aliases set in the spec, the built-in defaults are not valid public names
+func certificateDNSNames(deployment *aemv1beta1.AEMDeployment) []string {
+	spec := deployment.Spec.Dispatcher
+	if len(spec.TLS.Certificate.DNSNames) > 0 {
+		return spec.TLS.Certificate.DNSNames
+	}
+	names := []string{}
+	if spec.ServerName != "" {
+		names = append(names, spec.ServerName)
+	}
+	return append(names, spec.ServerAliases...)
+}
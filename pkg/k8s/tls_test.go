@@ -0,0 +1,174 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDispatcherTLSSecretName(t *testing.T) {
+	table := []struct {
+		tls    *aemv1beta1.DispatcherTLSSpec
+		secret string
+	}{
+		{tls: nil, secret: ""},
+		{tls: &aemv1beta1.DispatcherTLSSpec{SecretName: "www-tls"}, secret: "www-tls"},
+		{tls: &aemv1beta1.DispatcherTLSSpec{Certificate: &aemv1beta1.DispatcherCertificateSpec{IssuerName: "letsencrypt"}}, secret: "dev-dispatcher-tls"},
+	}
+	for _, i := range table {
+		deployment := &aemv1beta1.AEMDeployment{}
+		deployment.Name = "dev"
+		deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{TLS: i.tls}
+		secret := DispatcherTLSSecretName(deployment)
+		if secret != i.secret {
+			t.Errorf("got: %v exected: %v", secret, i.secret)
+		}
+	}
+}
+
+func TestApplyDispatcherTLS(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		TLS: &aemv1beta1.DispatcherTLSSpec{SecretName: "www-tls"},
+	}
+	pod := NewPod("dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	found := false
+	for _, volume := range pod.Spec.Volumes {
+		found = found || (volume.Secret != nil && volume.Secret.SecretName == "www-tls")
+	}
+	if !found {
+		t.Errorf("certificate secret not mounted: %+v", pod.Spec.Volumes)
+	}
+	for _, container := range pod.Spec.Containers {
+		mounted := false
+		for _, mount := range container.VolumeMounts {
+			mounted = mounted || mount.MountPath == DispatcherTLSDir
+		}
+		if mounted != (container.Name == "dev-dispatcher") {
+			t.Errorf("got mounted: %v in %s", mounted, container.Name)
+		}
+	}
+}
+
+func TestSetUpDispatcherCertificate(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		ServerName: "www.example.com",
+		TLS: &aemv1beta1.DispatcherTLSSpec{
+			Certificate: &aemv1beta1.DispatcherCertificateSpec{IssuerName: "letsencrypt", IssuerKind: "ClusterIssuer"},
+		},
+	}
+	cli := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	err := SetUpDispatcherCertificate(cli, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment.Spec.Dispatcher.ServerAliases = []string{"example.com"}
+	err = SetUpDispatcherCertificate(cli, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := cli.Resource(CertificateResource).Namespace("demo").Get("dev-dispatcher", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+	kind, _, _ := unstructured.NestedString(certificate.Object, "spec", "issuerRef", "kind")
+	names, _, _ := unstructured.NestedSlice(certificate.Object, "spec", "dnsNames")
+	if secret != "dev-dispatcher-tls" || kind != "ClusterIssuer" || len(names) != 2 {
+		t.Errorf("got certificate: %+v", certificate.Object)
+	}
+}
+
+func TestCreateExternalEndpointTLS(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	client := fake.NewSimpleClientset()
+	ingresses := NewIngressReconciler(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), IngressResource)
+	err := CreateExternalEndpoint(client, EndpointReconcilers{Ingresses: ingresses}, "dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		TLS: &aemv1beta1.DispatcherTLSSpec{SecretName: "www-tls"},
+	}
+	err = CreateExternalEndpoint(client, EndpointReconcilers{Ingresses: ingresses}, "dev-dispatcher-001", AEMRunmodeDispatcher, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := client.CoreV1().Services("demo").Get(MakeServiceName("dev-dispatcher-001"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Spec.Ports[0].Port != 443 || svc.Spec.Ports[0].TargetPort.IntVal != 443 || svc.Annotations[contourUpstreamTLSAnnotation] != "https" {
+		t.Errorf("got service: %+v", svc)
+	}
+	ingress, err := ingresses.client.Resource(IngressResource).Namespace("demo").Get(MakeIngressName("dev-dispatcher-001"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "http", "paths")
+	port, _, _ := unstructured.NestedInt64(paths[0].(map[string]interface{}), "backend", "service", "port", "number")
+	if port != 443 {
+		t.Errorf("got backend port: %d", port)
+	}
+
+	// ingress-nginx is told with an annotation of the ingress, unknown controllers keep using HTTP
+	table := []struct {
+		class    string
+		port     int
+		protocol string
+	}{
+		{class: "nginx", port: 443, protocol: "HTTPS"},
+		{class: "traefik", port: 80},
+	}
+	for _, i := range table {
+		deployment.Spec.Exposure = &aemv1beta1.ExposureSpec{IngressClass: i.class}
+		port, serviceAnnotations := instanceServicePort(AEMRunmodeDispatcher, deployment)
+		annotations := dispatcherIngressAnnotations(map[string]string{}, deployment)
+		if int(port.Port) != i.port || port.TargetPort.IntVal != int32(i.port) || len(serviceAnnotations) != 0 ||
+			annotations[nginxBackendProtocolAnnotation] != i.protocol {
+			t.Errorf("%s got port: %+v annotations: %v %v", i.class, port, serviceAnnotations, annotations)
+		}
+	}
+}
+
+func TestDispatcherConfigHashTLS(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		TLS: &aemv1beta1.DispatcherTLSSpec{SecretName: "www-tls"},
+	}
+	client := fake.NewSimpleClientset()
+	pending, err := DispatcherConfigHash(client, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "www-tls", Namespace: "demo"},
+		Data:       map[string][]byte{"tls.crt": []byte("crt"), "tls.key": []byte("key")},
+	}
+	secret, _ = client.CoreV1().Secrets("demo").Create(secret)
+	issued, _ := DispatcherConfigHash(client, deployment)
+	if issued == pending {
+		t.Error("hash not changed when the certificate is issued")
+	}
+	secret.Data["tls.crt"] = []byte("renewed")
+	client.CoreV1().Secrets("demo").Update(secret)
+	renewed, _ := DispatcherConfigHash(client, deployment)
+	if renewed == issued {
+		t.Error("hash not changed when the certificate is renewed")
+	}
+}
@@ -0,0 +1,343 @@
+package k8s
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/dispatcher"
+)
+
+// reservedEnvVars are set by the operator and can not be overridden in the spec
+var reservedEnvVars = []string{EnvCQRunmode, EnvCQPort, EnvCQJVMOpts}
+
+// hostRegexp matches the DNS names allowed in the hosts of the ingresses
+var hostRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// ValidateDeployment checks the specification of a deployment before its resources are created.
+func ValidateDeployment(deployment *aemv1beta1.AEMDeployment) error {
+	for _, runmode := range []string{AEMRunmodeAuthor, AEMRunmodePublish} {
+		err := validateInstanceSpec(runmode, deployment.Spec.InstanceSpecFor(runmode))
+		if err != nil {
+			return fmt.Errorf("%s: %v", runmode, err)
+		}
+	}
+	if deployment.Spec.Dispatcher != nil {
+		err := validateDispatcherSpec(deployment.Spec.Dispatcher)
+		if err != nil {
+			return fmt.Errorf("dispatcher: %v", err)
+		}
+	}
+	if dispatcherConfigSource(deployment) == nil {
+		err := validateDispatcherConfigMap(deployment)
+		if err != nil {
+			return fmt.Errorf("dispatcher: %v", err)
+		}
+	}
+	if deployment.Spec.Exposure != nil {
+		err := validateExposureSpec(deployment)
+		if err != nil {
+			return fmt.Errorf("exposure: %v", err)
+		}
+	}
+	// the routes send plain HTTP to the service port of the dispatchers
+	dispatcher := deployment.Spec.Dispatcher
+	if dispatcher != nil && dispatcher.TLS != nil && exposureMode(deployment, exposureDefaults()) == aemv1beta1.ExposureModeGatewayAPI {
+		return fmt.Errorf("dispatcher: tls requires the Ingress exposure mode")
+	}
+	return nil
+}
+
+// validateExposureSpec checks the issuer and that the host template returns valid hosts
+func validateExposureSpec(deployment *aemv1beta1.AEMDeployment) error {
+	exposure := deployment.Spec.Exposure
+	if issuer := exposure.Issuer; issuer != nil {
+		if issuer.Name == "" {
+			return fmt.Errorf("issuer has no name")
+		}
+		if issuer.Kind != "" && issuer.Kind != "Issuer" && issuer.Kind != clusterIssuerKind {
+			return fmt.Errorf("unknown issuer kind %q", issuer.Kind)
+		}
+	}
+	for key := range exposure.Annotations {
+		if key == "" || strings.ContainsAny(key, " \t\r\n") {
+			return fmt.Errorf("invalid annotation %q", key)
+		}
+	}
+	switch exposure.Mode {
+	case "", aemv1beta1.ExposureModeIngress, aemv1beta1.ExposureModeGatewayAPI:
+	default:
+		return fmt.Errorf("unknown mode %q", exposure.Mode)
+	}
+	// the access restrictions of the authors are ingress-nginx annotations, they are checked
+	// against the mode and the ingress class resolved with the defaults of the operator
+	defaults := exposureDefaults()
+	author := exposure.Author
+	restricted := author != nil && (len(author.AllowedSourceRanges) > 0 || author.Auth != nil)
+	switch exposureMode(deployment, defaults) {
+	case aemv1beta1.ExposureModeIngress:
+		if class := ingressClass(exposure, defaults); restricted && !isNginxIngressClass(class) {
+			return fmt.Errorf("the author source ranges and auth require an nginx ingress class, got %q", class)
+		}
+	case aemv1beta1.ExposureModeGatewayAPI:
+		if restricted {
+			return fmt.Errorf("the author source ranges and auth require the Ingress mode")
+		}
+	}
+	if exposure.Gateway != nil && exposure.Gateway.Name == "" {
+		return fmt.Errorf("gateway has no name")
+	}
+	err := validateExposurePolicies(exposure)
+	if err != nil {
+		return err
+	}
+	if site := exposure.Site; site != nil {
+		if len(site.Hosts) == 0 {
+			return fmt.Errorf("site has no hosts")
+		}
+		for _, host := range site.Hosts {
+			if !hostRegexp.MatchString(host) {
+				return fmt.Errorf("invalid site host %q", host)
+			}
+		}
+	}
+	if exposure.HostTemplate == "" {
+		return nil
+	}
+	host, err := executeHostTemplate(exposure.HostTemplate, hostTemplateData{
+		Instance:   MakePodName(deployment.Name, AEMRunmodeAuthor, "001"),
+		Runmode:    AEMRunmodeAuthor,
+		Deployment: deployment.Name,
+		Namespace:  deployment.Namespace,
+		Domain:     "example.com",
+	})
+	if err != nil {
+		return err
+	}
+	if !hostRegexp.MatchString(host) {
+		return fmt.Errorf("host template returns an invalid host %q", host)
+	}
+	return nil
+}
+
+// validateExposurePolicies checks the policies of the runmodes and the access restrictions of the authors
+func validateExposurePolicies(exposure *aemv1beta1.ExposureSpec) error {
+	policies := map[string]aemv1beta1.ExposurePolicy{}
+	if exposure.Publish != nil {
+		policies[AEMRunmodePublish] = exposure.Publish.Policy
+	}
+	if exposure.Dispatcher != nil {
+		policies[AEMRunmodeDispatcher] = exposure.Dispatcher.Policy
+	}
+	author := exposure.Author
+	if author == nil {
+		author = &aemv1beta1.AuthorExposure{}
+	}
+	policies[AEMRunmodeAuthor] = author.Policy
+	for runmode, policy := range policies {
+		switch policy {
+		case "", aemv1beta1.ExposurePublic, aemv1beta1.ExposureInternal, aemv1beta1.ExposureNone:
+		default:
+			return fmt.Errorf("unknown %s policy %q", runmode, policy)
+		}
+	}
+	for _, cidr := range author.AllowedSourceRanges {
+		_, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid author source range %q", cidr)
+		}
+	}
+	auth := author.Auth
+	if auth == nil {
+		return nil
+	}
+	if auth.BasicAuthSecretName != "" && auth.OAuth2Proxy != nil {
+		return fmt.Errorf("author auth sets both basicAuthSecretName and oauth2Proxy")
+	}
+	if auth.BasicAuthSecretName == "" && auth.OAuth2Proxy == nil {
+		return fmt.Errorf("author auth sets neither basicAuthSecretName nor oauth2Proxy")
+	}
+	if auth.OAuth2Proxy != nil {
+		u, err := url.Parse(auth.OAuth2Proxy.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid oauth2Proxy url %q", auth.OAuth2Proxy.URL)
+		}
+	}
+	return nil
+}
+
+// validateDispatcherConfigMap checks the generated farm before it reaches the configMap
+func validateDispatcherConfigMap(deployment *aemv1beta1.AEMDeployment) error {
+	data, err := dispatcherConfigMapData(deployment)
+	if err != nil {
+		return err
+	}
+	nodes, err := dispatcher.Parse([]byte(data[DispatcherPublishConfigKey]))
+	if err == nil {
+		err = dispatcher.Validate(nodes)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", DispatcherPublishConfigKey, err)
+	}
+	return nil
+}
+
+func validateInstanceSpec(runmode string, instance *aemv1beta1.InstanceSpec) error {
+	if _, ok := sizeProfiles[strings.ToLower(instance.Type)]; !ok && instance.Type != "" {
+		return fmt.Errorf("unknown type %q", instance.Type)
+	}
+	for _, mode := range instance.RunModes {
+		if mode == AEMRunmodeAuthor || mode == AEMRunmodePublish {
+			return fmt.Errorf("run mode %q is set by the operator", mode)
+		}
+		if mode == "" || strings.Contains(mode, ",") {
+			return fmt.Errorf("invalid run mode %q", mode)
+		}
+	}
+	for _, env := range instance.Env {
+		for _, reserved := range reservedEnvVars {
+			if env.Name == reserved {
+				return fmt.Errorf("environment variable %s is reserved", env.Name)
+			}
+		}
+	}
+	volumes := map[string]bool{AEMCRXVolumeName: true}
+	for _, volume := range instance.Volumes {
+		if volumes[volume.Name] {
+			return fmt.Errorf("duplicated volume %q", volume.Name)
+		}
+		volumes[volume.Name] = true
+	}
+	for _, mount := range instance.VolumeMounts {
+		if !volumes[mount.Name] || mount.Name == AEMCRXVolumeName {
+			return fmt.Errorf("volume mount %q does not reference an extra volume", mount.Name)
+		}
+		if strings.HasPrefix(mount.MountPath, AEMCRXMountPath) {
+			return fmt.Errorf("volume mount %q can not be mounted in %s", mount.Name, AEMCRXMountPath)
+		}
+	}
+	return nil
+}
+
+func validateDispatcherSpec(dispatcher *aemv1beta1.DispatcherSpec) error {
+	if dispatcher.PairingWeight < 0 {
+		return fmt.Errorf("invalid pairing weight %d", dispatcher.PairingWeight)
+	}
+	names := append([]string{dispatcher.ServerName}, dispatcher.ServerAliases...)
+	for _, name := range names {
+		if strings.ContainsAny(name, " \t\r\n") {
+			return fmt.Errorf("invalid server name %q", name)
+		}
+	}
+	values := append(append([]string{}, dispatcher.VirtualHosts...), dispatcher.ClientHeaders...)
+	for i, filter := range dispatcher.Filters {
+		properties := []string{filter.Glob, filter.Method, filter.URL, filter.Query, filter.Protocol,
+			filter.Path, filter.Selectors, filter.Extension, filter.Suffix}
+		if strings.Join(properties, "") == "" {
+			return fmt.Errorf("filter %d has no glob or request line element", i+1)
+		}
+		if err := validateRuleType(filter.Type); err != nil {
+			return fmt.Errorf("filter %d: %v", i+1, err)
+		}
+		values = append(values, properties...)
+	}
+	sections := []struct {
+		name  string
+		rules []aemv1beta1.DispatcherRule
+	}{
+		{"cache rule", dispatcher.CacheRules},
+		{"invalidate rule", dispatcher.InvalidateRules},
+		{"allowed client", dispatcher.AllowedClients},
+	}
+	for _, section := range sections {
+		for i, rule := range section.rules {
+			if rule.Glob == "" {
+				return fmt.Errorf("%s %d has no glob", section.name, i+1)
+			}
+			if err := validateRuleType(rule.Type); err != nil {
+				return fmt.Errorf("%s %d: %v", section.name, i+1, err)
+			}
+			values = append(values, rule.Glob)
+		}
+	}
+	// values are quoted in the farm
+	for _, value := range values {
+		if strings.ContainsAny(value, "\"\r\n") {
+			return fmt.Errorf("invalid value %q", value)
+		}
+	}
+	if dispatcher.TLS != nil {
+		if err := validateDispatcherTLS(dispatcher); err != nil {
+			return err
+		}
+	}
+	if dispatcher.Cache != nil {
+		if err := validateDispatcherCache(dispatcher.Cache); err != nil {
+			return err
+		}
+	}
+	if dispatcher.ConfigFrom != nil {
+		return validateDispatcherConfigSource(dispatcher.ConfigFrom)
+	}
+	return nil
+}
+
+func validateDispatcherCache(cache *aemv1beta1.DispatcherCacheSpec) error {
+	switch cache.Medium {
+	case "", aemv1beta1.DispatcherCacheDisk, aemv1beta1.DispatcherCacheMemory:
+		if cache.StorageClassName != nil {
+			return fmt.Errorf("cache storage class requires the %s medium", aemv1beta1.DispatcherCachePersistentVolume)
+		}
+	case aemv1beta1.DispatcherCachePersistentVolume:
+	default:
+		return fmt.Errorf("unknown cache medium %q", cache.Medium)
+	}
+	if cache.SizeLimit != nil && cache.SizeLimit.Sign() <= 0 {
+		return fmt.Errorf("invalid cache size limit %s", cache.SizeLimit.String())
+	}
+	return nil
+}
+
+func validateDispatcherTLS(dispatcher *aemv1beta1.DispatcherSpec) error {
+	certificate := dispatcher.TLS.Certificate
+	if certificate == nil {
+		if dispatcher.TLS.SecretName == "" {
+			return fmt.Errorf("tls requires a secretName or a certificate")
+		}
+		return nil
+	}
+	if certificate.IssuerName == "" {
+		return fmt.Errorf("tls certificate has no issuerName")
+	}
+	if certificate.IssuerKind != "" && certificate.IssuerKind != "Issuer" && certificate.IssuerKind != "ClusterIssuer" {
+		return fmt.Errorf("tls certificate has an unknown issuerKind %q", certificate.IssuerKind)
+	}
+	if len(certificate.DNSNames) == 0 && dispatcher.ServerName == "" && len(dispatcher.ServerAliases) == 0 {
+		return fmt.Errorf("tls certificate requires dnsNames or a serverName")
+	}
+	return nil
+}
+
+func validateDispatcherConfigSource(source *aemv1beta1.DispatcherConfigSource) error {
+	if (source.ConfigMapName == "") == (source.SecretName == "") {
+		return fmt.Errorf("configFrom requires either a configMapName or a secretName")
+	}
+	if source.MountPath != "" && !path.IsAbs(source.MountPath) {
+		return fmt.Errorf("configFrom mount path %q is not absolute", source.MountPath)
+	}
+	if strings.HasPrefix(path.Clean(source.MountPath)+"/", DispatcherCacheDir+"/") {
+		return fmt.Errorf("configFrom can not be mounted in %s", DispatcherCacheDir)
+	}
+	return nil
+}
+
+func validateRuleType(ruleType string) error {
+	if ruleType != aemv1beta1.DispatcherRuleAllow && ruleType != aemv1beta1.DispatcherRuleDeny {
+		return fmt.Errorf("unknown type %q", ruleType)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package k8s
+
+import (
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestValidateInstanceSpec(t *testing.T) {
+	table := []struct {
+		instance aemv1beta1.InstanceSpec
+		valid    bool
+	}{
+		{instance: aemv1beta1.InstanceSpec{Type: "small", RunModes: []string{"stage"}}, valid: true},
+		{instance: aemv1beta1.InstanceSpec{Type: "huge"}, valid: false},
+		{instance: aemv1beta1.InstanceSpec{RunModes: []string{"publish"}}, valid: false},
+		{instance: aemv1beta1.InstanceSpec{Env: []v1.EnvVar{{Name: EnvCQPort, Value: "4505"}}}, valid: false},
+		{instance: aemv1beta1.InstanceSpec{Env: []v1.EnvVar{{Name: "TZ", Value: "UTC"}}}, valid: true},
+		{
+			instance: aemv1beta1.InstanceSpec{
+				Volumes:      []v1.Volume{{Name: "packages"}},
+				VolumeMounts: []v1.VolumeMount{{Name: "packages", MountPath: "/opt/packages"}},
+			},
+			valid: true,
+		},
+		{instance: aemv1beta1.InstanceSpec{VolumeMounts: []v1.VolumeMount{{Name: AEMCRXVolumeName, MountPath: "/opt/crx"}}}, valid: false},
+		{instance: aemv1beta1.InstanceSpec{Volumes: []v1.Volume{{Name: AEMCRXVolumeName}}}, valid: false},
+	}
+	for _, i := range table {
+		err := validateInstanceSpec(AEMRunmodeAuthor, &i.instance)
+		if (err == nil) != i.valid {
+			t.Errorf("got: %v exected valid: %v for %+v", err, i.valid, i.instance)
+		}
+	}
+}
+
+func TestValidateDispatcherSpec(t *testing.T) {
+	cacheSize := resource.MustParse("1Gi")
+	zero := resource.MustParse("0")
+	storageClass := "gp2"
+	table := []struct {
+		dispatcher aemv1beta1.DispatcherSpec
+		valid      bool
+	}{
+		{dispatcher: aemv1beta1.DispatcherSpec{ServerName: "www.example.com", VirtualHosts: []string{"*.example.com"}}, valid: true},
+		{dispatcher: aemv1beta1.DispatcherSpec{ServerName: "www.example.com\nInclude /etc/passwd"}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{Filters: []aemv1beta1.DispatcherFilter{{Type: "allow", Extension: "'(css|js)'"}}}, valid: true},
+		{dispatcher: aemv1beta1.DispatcherSpec{Filters: []aemv1beta1.DispatcherFilter{{Type: "allow"}}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{Filters: []aemv1beta1.DispatcherFilter{{Type: "block", URL: "/content/*"}}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{CacheRules: []aemv1beta1.DispatcherRule{{Glob: "*.html", Type: "allow"}}}, valid: true},
+		{dispatcher: aemv1beta1.DispatcherSpec{AllowedClients: []aemv1beta1.DispatcherRule{{Type: "allow"}}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{ConfigFrom: &aemv1beta1.DispatcherConfigSource{ConfigMapName: "dispatcher-tree"}}, valid: true},
+		{dispatcher: aemv1beta1.DispatcherSpec{ConfigFrom: &aemv1beta1.DispatcherConfigSource{ConfigMapName: "tree", SecretName: "tree"}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{ConfigFrom: &aemv1beta1.DispatcherConfigSource{SecretName: "tree", MountPath: "etc/httpd"}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{TLS: &aemv1beta1.DispatcherTLSSpec{SecretName: "www-tls"}}, valid: true},
+		{dispatcher: aemv1beta1.DispatcherSpec{TLS: &aemv1beta1.DispatcherTLSSpec{}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{ServerName: "www.example.com", TLS: &aemv1beta1.DispatcherTLSSpec{Certificate: &aemv1beta1.DispatcherCertificateSpec{IssuerName: "letsencrypt"}}}, valid: true},
+		{dispatcher: aemv1beta1.DispatcherSpec{TLS: &aemv1beta1.DispatcherTLSSpec{Certificate: &aemv1beta1.DispatcherCertificateSpec{IssuerName: "letsencrypt"}}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{ServerName: "www.example.com", TLS: &aemv1beta1.DispatcherTLSSpec{Certificate: &aemv1beta1.DispatcherCertificateSpec{IssuerName: "letsencrypt", IssuerKind: "Vault"}}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{Cache: &aemv1beta1.DispatcherCacheSpec{Medium: aemv1beta1.DispatcherCacheMemory, SizeLimit: &cacheSize}}, valid: true},
+		{dispatcher: aemv1beta1.DispatcherSpec{Cache: &aemv1beta1.DispatcherCacheSpec{Medium: "tmpfs"}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{Cache: &aemv1beta1.DispatcherCacheSpec{SizeLimit: &zero}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{Cache: &aemv1beta1.DispatcherCacheSpec{StorageClassName: &storageClass}}, valid: false},
+		{dispatcher: aemv1beta1.DispatcherSpec{InvalidateRules: []aemv1beta1.DispatcherRule{{Glob: "*.html\" }", Type: "allow"}}}, valid: false},
+	}
+	for _, i := range table {
+		err := validateDispatcherSpec(&i.dispatcher)
+		if (err == nil) != i.valid {
+			t.Errorf("got: %v exected valid: %v for %+v", err, i.valid, i.dispatcher)
+		}
+	}
+}
+
+func TestValidateDeployment(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "dev"
+	deployment.Namespace = "demo"
+	deployment.Spec.Publishers.Replicas = 2
+	err := ValidateDeployment(deployment)
+	if err != nil {
+		t.Errorf("unexpected error for the default dispatcher configuration: %v", err)
+	}
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{
+		Filters: []aemv1beta1.DispatcherFilter{{Type: "allow", Extension: "'(css|js'"}},
+	}
+	err = ValidateDeployment(deployment)
+	if err == nil {
+		t.Errorf("expected error for an invalid regular expression")
+	}
+
+	// the routes of the GatewayAPI mode of the operator can not reach the dispatchers over TLS
+	cfg := config.Default()
+	cfg.Exposure.Mode = aemv1beta1.ExposureModeGatewayAPI
+	cfg.Exposure.Gateway = &aemv1beta1.GatewayReference{Name: "public"}
+	config.Set(cfg)
+	defer config.Set(nil)
+	deployment.Spec.Dispatcher = &aemv1beta1.DispatcherSpec{TLS: &aemv1beta1.DispatcherTLSSpec{SecretName: "site-tls"}}
+	err = ValidateDeployment(deployment)
+	if err == nil {
+		t.Errorf("expected error for dispatcher TLS in the GatewayAPI mode")
+	}
+	deployment.Spec.Dispatcher = nil
+	deployment.Spec.Exposure = &aemv1beta1.ExposureSpec{IngressClass: "nginx", Author: &aemv1beta1.AuthorExposure{AllowedSourceRanges: []string{"10.0.0.0/8"}}}
+	err = ValidateDeployment(deployment)
+	if err == nil {
+		t.Errorf("expected error for author restrictions in the GatewayAPI mode of the operator")
+	}
+}
+
+func TestValidateExposureSpec(t *testing.T) {
+	table := []struct {
+		exposure aemv1beta1.ExposureSpec
+		valid    bool
+	}{
+		{exposure: aemv1beta1.ExposureSpec{HostTemplate: "{{.Instance}}.{{.Namespace}}.example.com"}, valid: true},
+		{exposure: aemv1beta1.ExposureSpec{HostTemplate: "{{.Instance}"}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{HostTemplate: "{{.Pod}}.example.com"}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{HostTemplate: "{{.Instance}} example.com"}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Issuer: &aemv1beta1.IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"}}, valid: true},
+		{exposure: aemv1beta1.ExposureSpec{Issuer: &aemv1beta1.IssuerReference{Kind: "Issuer"}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Annotations: map[string]string{"": "true"}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Publish: &aemv1beta1.RunmodeExposure{Policy: "Public"}, Dispatcher: &aemv1beta1.RunmodeExposure{Policy: "None"}}, valid: true},
+		{exposure: aemv1beta1.ExposureSpec{Publish: &aemv1beta1.RunmodeExposure{Policy: "Private"}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Site: &aemv1beta1.SiteExposure{Hosts: []string{"www.example.com", "example.com"}}}, valid: true},
+		{exposure: aemv1beta1.ExposureSpec{Site: &aemv1beta1.SiteExposure{}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Mode: "GatewayAPI", Gateway: &aemv1beta1.GatewayReference{Name: "public", Namespace: "gateways"}}, valid: true},
+		{exposure: aemv1beta1.ExposureSpec{Mode: "Route"}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Mode: "GatewayAPI", Gateway: &aemv1beta1.GatewayReference{SectionName: "https"}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Mode: "GatewayAPI", Author: &aemv1beta1.AuthorExposure{AllowedSourceRanges: []string{"10.0.0.0/8"}}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Site: &aemv1beta1.SiteExposure{Hosts: []string{"https://www.example.com"}}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{Author: &aemv1beta1.AuthorExposure{AllowedSourceRanges: []string{"10.0.0.0/8"}}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{IngressClass: "contour", Author: &aemv1beta1.AuthorExposure{Auth: &aemv1beta1.IngressAuthSpec{BasicAuthSecretName: "author-htpasswd"}}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{IngressClass: "nginx", Author: &aemv1beta1.AuthorExposure{AllowedSourceRanges: []string{"10.0.0.0/8", "203.0.113.7/32"}}}, valid: true},
+		{exposure: aemv1beta1.ExposureSpec{IngressClass: "nginx", Author: &aemv1beta1.AuthorExposure{AllowedSourceRanges: []string{"203.0.113.7"}}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{IngressClass: "nginx", Author: &aemv1beta1.AuthorExposure{Auth: &aemv1beta1.IngressAuthSpec{BasicAuthSecretName: "author-htpasswd"}}}, valid: true},
+		{exposure: aemv1beta1.ExposureSpec{IngressClass: "nginx", Author: &aemv1beta1.AuthorExposure{Auth: &aemv1beta1.IngressAuthSpec{}}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{IngressClass: "nginx", Author: &aemv1beta1.AuthorExposure{Auth: &aemv1beta1.IngressAuthSpec{OAuth2Proxy: &aemv1beta1.OAuth2ProxySpec{URL: "auth.example.com"}}}}, valid: false},
+		{exposure: aemv1beta1.ExposureSpec{IngressClass: "nginx", Author: &aemv1beta1.AuthorExposure{Auth: &aemv1beta1.IngressAuthSpec{
+			BasicAuthSecretName: "author-htpasswd",
+			OAuth2Proxy:         &aemv1beta1.OAuth2ProxySpec{URL: "https://auth.example.com"},
+		}}}, valid: false},
+	}
+	for _, i := range table {
+		deployment := &aemv1beta1.AEMDeployment{}
+		deployment.Name = "dev"
+		deployment.Namespace = "demo"
+		deployment.Spec.Exposure = &i.exposure
+		err := validateExposureSpec(deployment)
+		if (err == nil) != i.valid {
+			t.Errorf("got: %v exected valid: %v for %+v", err, i.valid, i.exposure)
+		}
+	}
+
+	// the author restrictions are allowed by the nginx ingress class of the operator
+	cfg := config.Default()
+	cfg.Exposure.IngressClass = "nginx-internal"
+	config.Set(cfg)
+	defer config.Set(nil)
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Spec.Exposure = &aemv1beta1.ExposureSpec{Author: &aemv1beta1.AuthorExposure{AllowedSourceRanges: []string{"10.0.0.0/8"}}}
+	err := validateExposureSpec(deployment)
+	if err != nil {
+		t.Errorf("got: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package operator
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
+)
+
+const flushAPIPrefix = "/flush/"
+
+// flushRequest is the body of a flush request to the operator API.
+type flushRequest struct {
+	Paths []string `json:"paths"`
+	Purge bool     `json:"purge"`
+}
+
+// serveAPI serves the operator API until stop is closed, the requests must send the token of the
+// configuration as a bearer token. The API is not served without a token.
+func (ac *AEMDeploymentController) serveAPI(stop <-chan struct{}) {
+	api := ac.config.API
+	if api.Token == "" {
+		ac.logger.Errorf("Not serving the operator API, %s is not set", config.EnvAPIToken)
+		return
+	}
+	server := &http.Server{Addr: api.Address, Handler: ac.apiHandler(api.Token)}
+	go func() {
+		<-stop
+		server.Close()
+	}()
+	ac.logger.Infof("Serving the operator API at %s", api.Address)
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		ac.logger.Errorf("Error serving the operator API: %v", err)
+	}
+}
+
+// apiHandler returns the handler of the operator API:
+//
+//	POST /flush/<namespace>/<deployment> {"paths": ["/content/site"]} invalidates the paths in every dispatcher
+//	POST /flush/<namespace>/<deployment> {"purge": true} deletes every cached file
+func (ac *AEMDeploymentController) apiHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(flushAPIPrefix, func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		segs := strings.Split(strings.TrimPrefix(r.URL.Path, flushAPIPrefix), "/")
+		if len(segs) != 2 || segs[0] == "" || segs[1] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		var body flushRequest
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		status := ac.flushDeployment(segs[0], aemv1beta1.CacheFlushSpec{
+			Deployment: segs[1],
+			Paths:      body.Paths,
+			Purge:      body.Purge,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		if status.Phase != aemv1beta1.CacheFlushPhaseCompleted {
+			code := http.StatusBadGateway
+			if status.Reason != "" {
+				code = http.StatusBadRequest
+			}
+			w.WriteHeader(code)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+	return mux
+}
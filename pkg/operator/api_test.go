@@ -0,0 +1,45 @@
+package operator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIHandler(t *testing.T) {
+	handler := getAEMDeploymentController(nil).apiHandler("secret")
+	table := []struct {
+		method string
+		path   string
+		token  string
+		body   string
+		code   int
+	}{
+		{method: http.MethodPost, path: "/flush/demo/dev", token: "", body: `{"purge": true}`, code: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/flush/demo/dev", token: "secret", body: "", code: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, path: "/flush/demo", token: "secret", body: `{"purge": true}`, code: http.StatusNotFound},
+		{method: http.MethodPost, path: "/flush/demo/dev", token: "secret", body: `{"paths": "/content"}`, code: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/flush/demo/dev", token: "secret", body: `{"paths": ["content"]}`, code: http.StatusBadRequest},
+	}
+	for _, i := range table {
+		req := httptest.NewRequest(i.method, i.path, strings.NewReader(i.body))
+		if i.token != "" {
+			req.Header.Set("Authorization", "Bearer "+i.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != i.code {
+			t.Errorf("got: %d exected: %d for %s %s %s", rec.Code, i.code, i.method, i.path, i.body)
+		}
+	}
+
+	// an empty token does not open the API
+	req := httptest.NewRequest(http.MethodPost, "/flush/demo/dev", strings.NewReader(`{"purge": true}`))
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	getAEMDeploymentController(nil).apiHandler("").ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got: %d without a token", rec.Code)
+	}
+}
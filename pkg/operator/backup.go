@@ -0,0 +1,210 @@
+package operator
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/k8s"
+	"k8s.io/api/core/v1"
+)
+
+const (
+	defaultMaxBackups = 5
+	// backupIDLayout is the time layout of the backup ids, ids sort in chronological order
+	backupIDLayout = "20060102150405"
+)
+
+var quiesceClient = &http.Client{Timeout: 60 * time.Second}
+
+// checkBackups takes a new backup of the instances when the backup interval has elapsed,
+// deletes the backups exceeding the retention and reflects the backups in the deployment status.
+func (ac *AEMDeploymentController) checkBackups(pods []*v1.Pod, deployment *aemv1beta1.AEMDeployment) error {
+	backup := deployment.Spec.Backup
+	if backup == nil || (backup.Mode != "" && backup.Mode != aemv1beta1.BackupModeSnapshot) {
+		return nil
+	}
+	snapshots, err := k8s.ListVolumeSnapshots(ac.dynamicClient, deployment)
+	if err != nil {
+		return err
+	}
+	backups := groupBackups(snapshots)
+
+	interval := time.Duration(backup.BackupIntervalInSecond) * time.Second
+	if interval > 0 && len(pods) > 0 && backupDue(backups, interval) {
+		id := time.Now().UTC().Format(backupIDLayout)
+		ac.logger.Infof("Starting backup %s of deployment %s", id, deployment.Name)
+		instances := []string{}
+		for _, pod := range pods {
+			instances = append(instances, pod.Name)
+			err := ac.backupInstance(pod, id, deployment)
+			if err != nil {
+				// a partial backup can not restore the deployment
+				cleanupErr := ac.deleteBackup(aemv1beta1.BackupStatus{ID: id, Instances: instances}, deployment)
+				if cleanupErr != nil {
+					ac.logger.Errorf("error deleting partial backup %s: %v", id, cleanupErr)
+				}
+				return fmt.Errorf("error backing up %s: %v", pod.Name, err)
+			}
+		}
+		sort.Strings(instances)
+		created := aemv1beta1.BackupStatus{
+			ID:           id,
+			CreationTime: time.Now().UTC().Format(time.RFC3339),
+			Instances:    instances,
+		}
+		backups = append([]aemv1beta1.BackupStatus{created}, backups...)
+	}
+
+	// Delete the backups exceeding the retention
+	maxBackups := backup.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxBackups
+	}
+	backups, expired := retainBackups(backups, maxBackups)
+	for _, b := range expired {
+		err := ac.deleteBackup(b, deployment)
+		if err != nil {
+			return err
+		}
+		ac.logger.Infof("Backup %s of deployment %s deleted", b.ID, deployment.Name)
+	}
+
+	if reflect.DeepEqual(deployment.Status.Backups, backups) {
+		return nil
+	}
+	deployment.Status.Backups = backups
+	_, err = ac.aemcli.AemV1beta1().AEMDeployments(deployment.Namespace).Update(deployment)
+	return err
+}
+
+// retainBackups splits the backups, newest first, into the kept and the expired backups. The newest
+// maxBackups ready backups are kept, the backups that are not ready are only expired when a newer
+// backup is ready as they can still be in progress.
+func retainBackups(backups []aemv1beta1.BackupStatus, maxBackups int) ([]aemv1beta1.BackupStatus, []aemv1beta1.BackupStatus) {
+	var kept, expired []aemv1beta1.BackupStatus
+	ready := 0
+	for _, b := range backups {
+		switch {
+		case b.Ready && ready < maxBackups:
+			kept = append(kept, b)
+			ready++
+		case !b.Ready && ready == 0:
+			kept = append(kept, b)
+		default:
+			expired = append(expired, b)
+		}
+	}
+	return kept, expired
+}
+
+// deleteBackup deletes the snapshots and the secrets of a backup
+func (ac *AEMDeploymentController) deleteBackup(b aemv1beta1.BackupStatus, deployment *aemv1beta1.AEMDeployment) error {
+	for _, instance := range b.Instances {
+		err := k8s.DeleteVolumeSnapshot(ac.dynamicClient, deployment.Namespace, k8s.MakeSnapshotName(k8s.MakePVCName(instance), b.ID))
+		if err != nil {
+			return err
+		}
+	}
+	err := ac.secrets.CleanUp(getBackupSecretPath(deployment.Namespace, deployment.Name, b.ID))
+	if err != nil {
+		ac.logger.Errorf("error cleaning up backup secrets: %v", err)
+	}
+	return nil
+}
+
+// backupInstance takes the snapshot of an instance, quiescing the instance if required,
+// and keeps the admin password of the instance so the backup can be restored after
+// the deployment is deleted.
+func (ac *AEMDeploymentController) backupInstance(pod *v1.Pod, backupID string, deployment *aemv1beta1.AEMDeployment) error {
+	pwd, err := ac.getPodPassword(pod, deployment.Name)
+	if err != nil {
+		return err
+	}
+	quiesce := deployment.Spec.Backup.Quiesce
+	if quiesce != nil && quiesce.PrePath != "" {
+		err := postInstance(pod, quiesce.PrePath, pwd)
+		if err != nil {
+			return err
+		}
+	}
+	err = k8s.CreateVolumeSnapshot(ac.dynamicClient, pod.Name, backupID, deployment)
+	if quiesce != nil && quiesce.PostPath != "" {
+		// the instance must be resumed even if the snapshot failed
+		postErr := postInstance(pod, quiesce.PostPath, pwd)
+		if err == nil {
+			err = postErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if pwd == "" {
+		return nil
+	}
+	return ac.secrets.Put(getBackupSecretKey(pod.Namespace, deployment.Name, backupID, pod.Name), map[string]interface{}{"password": pwd})
+}
+
+// postInstance makes an authenticated POST request to the given path of the AEM instance
+func postInstance(pod *v1.Pod, path, pwd string) error {
+	url := fmt.Sprintf("http://%s:%s%s", pod.Status.PodIP, getInstancePort(pod), path)
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("admin", pwd)
+	resp, err := quiesceClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("POST %s returned %s", path, resp.Status)
+	}
+	return nil
+}
+
+// groupBackups groups the snapshots by backup, the newest backup first
+func groupBackups(snapshots []k8s.VolumeSnapshot) []aemv1beta1.BackupStatus {
+	byID := map[string]*aemv1beta1.BackupStatus{}
+	ids := []string{}
+	for _, s := range snapshots {
+		if s.BackupID == "" {
+			continue
+		}
+		b, ok := byID[s.BackupID]
+		if !ok {
+			b = &aemv1beta1.BackupStatus{
+				ID:           s.BackupID,
+				CreationTime: s.CreationTime.UTC().Format(time.RFC3339),
+				Ready:        true,
+			}
+			byID[s.BackupID] = b
+			ids = append(ids, s.BackupID)
+		}
+		b.Ready = b.Ready && s.ReadyToUse
+		b.Instances = append(b.Instances, s.Instance)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	var backups []aemv1beta1.BackupStatus
+	for _, id := range ids {
+		sort.Strings(byID[id].Instances)
+		backups = append(backups, *byID[id])
+	}
+	return backups
+}
+
+// backupDue returns true if there is no backup or the newest backup is older than the interval
+func backupDue(backups []aemv1beta1.BackupStatus, interval time.Duration) bool {
+	if len(backups) == 0 {
+		return true
+	}
+	last, err := time.Parse(backupIDLayout, backups[0].ID)
+	if err != nil {
+		return true
+	}
+	return time.Since(last) >= interval
+}
@@ -0,0 +1,84 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/k8s"
+)
+
+func TestGroupBackups(t *testing.T) {
+	snapshots := []k8s.VolumeSnapshot{
+		{Instance: "dev-publish-001", BackupID: "20180101000000", ReadyToUse: true},
+		{Instance: "dev-author-001", BackupID: "20180102000000", ReadyToUse: true},
+		{Instance: "dev-author-001", BackupID: "20180101000000", ReadyToUse: true},
+		{Instance: "dev-publish-001", BackupID: "20180102000000", ReadyToUse: false},
+		{Instance: "dev-author-001"},
+	}
+	backups := groupBackups(snapshots)
+	if len(backups) != 2 {
+		t.Fatalf("Should have 2 backups, got: %v", len(backups))
+	}
+	if backups[0].ID != "20180102000000" {
+		t.Error("Should return the newest backup first")
+	}
+	if backups[0].Ready {
+		t.Error("Should not be ready until every snapshot is ready")
+	}
+	if !backups[1].Ready || len(backups[1].Instances) != 2 {
+		t.Errorf("Should be ready with 2 instances, got: %v", backups[1])
+	}
+	if groupBackups(nil) != nil {
+		t.Error("Should be nil without snapshots")
+	}
+}
+
+func TestBackupDue(t *testing.T) {
+	recent := time.Now().UTC().Add(-time.Minute).Format(backupIDLayout)
+	old := time.Now().UTC().Add(-2 * time.Hour).Format(backupIDLayout)
+	table := []struct {
+		backups []aemv1beta1.BackupStatus
+		output  bool
+	}{
+		{backups: nil, output: true},
+		{backups: []aemv1beta1.BackupStatus{{ID: recent}}, output: false},
+		{backups: []aemv1beta1.BackupStatus{{ID: old}}, output: true},
+	}
+	for _, i := range table {
+		got := backupDue(i.backups, time.Hour)
+		if got != i.output {
+			t.Errorf("got: %v exected: %v", got, i.output)
+		}
+	}
+}
+
+func TestRetainBackups(t *testing.T) {
+	backups := []aemv1beta1.BackupStatus{
+		{ID: "20180105000000"},
+		{ID: "20180104000000", Ready: true},
+		{ID: "20180103000000"},
+		{ID: "20180102000000", Ready: true},
+		{ID: "20180101000000", Ready: true},
+	}
+	ids := func(backups []aemv1beta1.BackupStatus) []string {
+		ids := []string{}
+		for _, b := range backups {
+			ids = append(ids, b.ID)
+		}
+		return ids
+	}
+	kept, expired := retainBackups(backups, 2)
+	// the backup in progress is kept, the unready backup older than a ready backup is expired
+	if !reflect.DeepEqual(ids(kept), []string{"20180105000000", "20180104000000", "20180102000000"}) {
+		t.Errorf("got kept: %v", ids(kept))
+	}
+	if !reflect.DeepEqual(ids(expired), []string{"20180103000000", "20180101000000"}) {
+		t.Errorf("got expired: %v", ids(expired))
+	}
+	kept, expired = retainBackups(backups[:1], 1)
+	if len(kept) != 1 || len(expired) != 0 {
+		t.Errorf("got kept: %v expired: %v", ids(kept), ids(expired))
+	}
+}
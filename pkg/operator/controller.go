@@ -1,20 +1,17 @@
 package operator
 
 import (
-	"os"
-	"time"
+	"sync"
 
 	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
 
+	"github.com/xumak-grid/aem-operator/pkg/config"
 	aemclientset "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned"
-	aeminformers "github.com/xumak-grid/aem-operator/pkg/generated/informers/externalversions/aem/v1beta1"
 	"github.com/xumak-grid/aem-operator/pkg/k8s"
 	"github.com/xumak-grid/aem-operator/pkg/secrets"
 	vault "github.com/xumak-grid/aem-operator/pkg/secrets/vault"
 	"go.uber.org/zap"
-	v1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/informers"
-	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -29,16 +26,31 @@ type AEMDeploymentController struct {
 	// RESTClient for mananging specific requests that are not part of the official resources.
 	restClient rest.Interface
 	// AEM clientset
-	aemcli      aemclientset.Interface
-	kubeconfig  *rest.Config
-	aemInformer cache.SharedIndexInformer
-	podInformer coreinformers.PodInformer
-	queue       workqueue.RateLimitingInterface
-	secrets     secrets.SecretService
+	aemcli     aemclientset.Interface
+	kubeconfig *rest.Config
+	queue      workqueue.RateLimitingInterface
+	secrets    secrets.SecretService
+
+	// Informers of the deployments, pods and cache flushes of each watched namespace.
+	namespaces   map[string]*namespaceInformers
+	namespacesMu sync.RWMutex
+	// namespaceInformer watches the namespaces matching the namespace selector, nil without one.
+	namespaceInformer cache.SharedIndexInformer
+
+	// Cache flushes are processed in their own queue so slow dispatchers do not delay the deployments.
+	flushQueue workqueue.RateLimitingInterface
+
+	// Dynamic client for resources without a typed client e.g. VolumeSnapshots.
+	dynamicClient dynamic.Interface
+	// Ingresses and HTTPRoutes are reconciled with the APIs served by the cluster.
+	endpoints k8s.EndpointReconcilers
+	// Operator configuration read at startup, the reloaded settings are read with config.Get.
+	config *config.Config
 }
 
-// NewAEMController creates a new controller for the AEM Operator.
-func NewAEMController(kubeconfig string, logger *zap.Logger) (*AEMDeploymentController, error) {
+// NewAEMController creates a new controller for the AEM Operator, the namespaces, the workers and
+// the secret backend of the configuration are fixed for its lifetime.
+func NewAEMController(kubeconfig string, operatorConfig *config.Config, logger *zap.Logger) (*AEMDeploymentController, error) {
 	cfg, err := k8s.BuildKubeConfig(kubeconfig)
 	if err != nil {
 		return nil, err
@@ -49,52 +61,72 @@ func NewAEMController(kubeconfig string, logger *zap.Logger) (*AEMDeploymentCont
 		return nil, err
 	}
 	aemcli, _ := aemclientset.NewForConfig(cfg)
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	sharedInformers := informers.NewSharedInformerFactory(clientSet, 15*time.Second)
-	secrets, err := vault.NewSecretService()
+	ingressResource, err := k8s.DetectIngressResource(clientSet.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	logger.Sugar().Infof("Using the %s ingress API", ingressResource.GroupVersion())
+	endpoints := k8s.EndpointReconcilers{Ingresses: k8s.NewIngressReconciler(dynamicClient, ingressResource)}
+	routeResource, ok, err := k8s.DetectHTTPRouteResource(clientSet.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		logger.Sugar().Infof("Using the %s HTTPRoute API", routeResource.GroupVersion())
+		endpoints.Routes = k8s.NewRouteReconciler(dynamicClient, routeResource)
+	}
+
+	secrets, err := vault.NewSecretService(operatorConfig.Secrets.Vault.Address, operatorConfig.Secrets.Vault.Token)
 	if err != nil {
 		return nil, err
 	}
 	aemc := &AEMDeploymentController{
-		kubeconfig:  cfg,
-		podInformer: sharedInformers.Core().V1().Pods(),
-		logger:      logger.Sugar(),
-		clientSet:   clientSet,
-		aemcli:      aemcli,
-		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "aemdeployment"),
-		secrets:     secrets,
-	}
-	aemc.aemInformer = aemc.newAEMControllerInformer()
-	aemc.aemInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    aemc.handleAddDeployment,
-		DeleteFunc: aemc.handleDeleteDeployment,
-		UpdateFunc: aemc.handleUpdateDeployment,
-	})
+		kubeconfig:    cfg,
+		config:        operatorConfig,
+		namespaces:    map[string]*namespaceInformers{},
+		logger:        logger.Sugar(),
+		clientSet:     clientSet,
+		aemcli:        aemcli,
+		dynamicClient: dynamicClient,
+		endpoints:     endpoints,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "aemdeployment"),
+		flushQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "aemcacheflush"),
+		secrets:       secrets,
+	}
+	if operatorConfig.NamespaceSelector != "" {
+		aemc.namespaceInformer = aemc.newNamespaceInformer()
+	}
 
 	return aemc, nil
 }
 
-func (ac *AEMDeploymentController) newAEMControllerInformer() cache.SharedIndexInformer {
-	ns := v1.NamespaceAll
-	if len(os.Getenv("DEV_OPERATOR_NAMESPACE")) > 0 {
-		ns = os.Getenv("DEV_OPERATOR_NAMESPACE")
-	}
-	resyncPeriod := 15 * time.Second
-	return aeminformers.NewAEMDeploymentInformer(ac.aemcli, ns, resyncPeriod, cache.Indexers{})
-}
-
 // Run runs the controller.
 func (ac *AEMDeploymentController) Run(stop <-chan struct{}) {
 	defer ac.queue.ShutDown()
-	// Run informers.
-	go ac.aemInformer.Run(stop)
-	go ac.podInformer.Informer().Run(stop)
-	// Wait for informers to be ready.
-	if !cache.WaitForCacheSync(stop, ac.aemInformer.HasSynced, ac.podInformer.Informer().HasSynced) {
-		ac.logger.Error("time out while waiting for cache sync")
+	defer ac.flushQueue.ShutDown()
+	defer ac.unwatchNamespaces()
+	// Run the informers of the configured namespaces and wait for them to be ready.
+	for _, ns := range ac.staticNamespaces() {
+		ac.watchNamespace(ns)
+	}
+	// The informers of the selected namespaces are started as the namespaces are labeled.
+	if ac.namespaceInformer != nil {
+		go ac.namespaceInformer.Run(stop)
+		if !cache.WaitForCacheSync(stop, ac.namespaceInformer.HasSynced) {
+			ac.logger.Error("time out while waiting for namespace cache sync")
+		}
 	}
 	ac.logger.Info("cache synced")
-	go ac.worker()
+	for i := 0; i < ac.config.Workers; i++ {
+		go ac.worker()
+	}
+	go ac.flushWorker()
+	go ac.serveAPI(stop)
 	<-stop
 }
 
@@ -182,3 +214,27 @@ func (ac *AEMDeploymentController) processNextWorkItem() bool {
 	ac.queue.AddRateLimited(key)
 	return true
 }
+
+func (ac *AEMDeploymentController) enqueueCacheFlush(obj interface{}) {
+	key, ok := ac.keyFunc(obj)
+	if ok {
+		ac.flushQueue.Add(key)
+	}
+}
+
+func (ac *AEMDeploymentController) flushWorker() {
+	for {
+		key, quit := ac.flushQueue.Get()
+		if quit {
+			return
+		}
+		err := ac.syncCacheFlush(key.(string))
+		if err == nil {
+			ac.flushQueue.Forget(key)
+		} else {
+			ac.logger.Errorf("Error processing cache flush %s: %v", key, err)
+			ac.flushQueue.AddRateLimited(key)
+		}
+		ac.flushQueue.Done(key)
+	}
+}
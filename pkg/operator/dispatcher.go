@@ -0,0 +1,51 @@
+package operator
+
+import (
+	"sort"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/k8s"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rollDispatchers restarts the dispatchers created with an outdated configuration, a single
+// dispatcher is deleted per sync and only when every pod of the deployment is healthy, the
+// dispatcher is created again with the current configuration by resizeDeployment.
+func (ac *AEMDeploymentController) rollDispatchers(pods []*v1.Pod, deployment *aemv1beta1.AEMDeployment) error {
+	if len(pods) == 0 {
+		return nil
+	}
+	hash, err := k8s.DispatcherConfigHash(ac.clientSet, deployment)
+	if err != nil {
+		return err
+	}
+	outdated := outdatedDispatchers(pods, hash)
+	if len(outdated) == 0 {
+		return nil
+	}
+	pod := outdated[0]
+	// the claim is deleted first, it is kept until the pod is gone and created again with the pod
+	deleted, err := k8s.DeleteOutdatedDispatcherClaim(ac.clientSet, pod.Name, deployment)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		ac.logger.Infof("Replacing the cache volume claim of dispatcher %s", pod.Name)
+	}
+	ac.logger.Infof("Restarting dispatcher %s, its configuration changed", pod.Name)
+	return ac.clientSet.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+}
+
+// outdatedDispatchers returns the dispatchers not created with the given configuration hash
+// in ascending ordinal order
+func outdatedDispatchers(pods []*v1.Pod, hash string) []*v1.Pod {
+	outdated := []*v1.Pod{}
+	for _, pod := range pods {
+		if pod.Annotations[k8s.DispatcherConfigHashAnnotation] != hash {
+			outdated = append(outdated, pod)
+		}
+	}
+	sort.Sort(ascendingOrdinal(outdated))
+	return outdated
+}
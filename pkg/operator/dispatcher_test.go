@@ -0,0 +1,60 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/xumak-grid/aem-operator/pkg/k8s"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOutdatedDispatchers(t *testing.T) {
+	dispatcher := func(name, hash string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{k8s.DispatcherConfigHashAnnotation: hash},
+		}}
+	}
+	pods := []*v1.Pod{
+		dispatcher("dev-dispatcher-003", "old"),
+		dispatcher("dev-dispatcher-001", "new"),
+		dispatcher("dev-dispatcher-002", "old"),
+		{ObjectMeta: metav1.ObjectMeta{Name: "dev-dispatcher-004"}},
+	}
+	outdated := outdatedDispatchers(pods, "new")
+	expected := []string{"dev-dispatcher-002", "dev-dispatcher-003", "dev-dispatcher-004"}
+	if len(outdated) != len(expected) {
+		t.Fatalf("got %d outdated dispatchers exected: %d", len(outdated), len(expected))
+	}
+	for i, pod := range outdated {
+		if pod.Name != expected[i] {
+			t.Errorf("got: %v exected: %v", pod.Name, expected[i])
+		}
+	}
+}
+
+func TestPublishFlushAgents(t *testing.T) {
+	pod := func(name string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "demo"},
+			Spec:       v1.PodSpec{Subdomain: "dev"},
+		}
+	}
+	dispatchers := []*v1.Pod{}
+	for _, name := range []string{"dev-dispatcher-001", "dev-dispatcher-002", "dev-dispatcher-003", "dev-dispatcher-004"} {
+		dispatchers = append(dispatchers, pod(name))
+	}
+	// more dispatchers than publishers, the dispatchers 003 and 004 render the publishers again
+	for _, publish := range []*v1.Pod{pod("dev-publish-001"), pod("dev-publish-002")} {
+		agents := publishFlushAgents(publish, dispatchers)
+		if len(agents) != len(dispatchers) {
+			t.Fatalf("%s got %d agents exected: %d", publish.Name, len(agents), len(dispatchers))
+		}
+		for i, agent := range agents {
+			uri := "http://" + dispatchers[i].Name + ".dev.demo:80/dispatcher/invalidate.cache"
+			if agent.With["jcr:title"] != dispatchers[i].Name || agent.With["transportUri"] != uri {
+				t.Errorf("%s got agent: %v", publish.Name, agent.With)
+			}
+		}
+	}
+}
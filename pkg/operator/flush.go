@@ -0,0 +1,127 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/dispatcher"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var (
+	flushClient = dispatcher.NewFlushClient(30 * time.Second)
+	// dispatcherFlushPort is the port of the virtual host handling the flush requests
+	dispatcherFlushPort = "80"
+)
+
+// syncCacheFlush processes a cache flush once, the result is kept in its status.
+func (ac *AEMDeploymentController) syncCacheFlush(key string) error {
+	obj, exists, err := ac.getCacheFlush(key)
+	if err != nil || !exists {
+		return err
+	}
+	flush := obj.DeepCopy()
+	if flush.Status.Phase != aemv1beta1.CacheFlushPhaseNone {
+		return nil
+	}
+	ac.logger.Infof("Flushing the dispatchers of %s/%s", flush.Namespace, flush.Spec.Deployment)
+	flush.Status = ac.flushDeployment(flush.Namespace, flush.Spec)
+	_, err = ac.aemcli.AemV1beta1().AEMCacheFlushes(flush.Namespace).Update(flush)
+	return err
+}
+
+// flushDeployment flushes every dispatcher of the deployment named in the spec.
+func (ac *AEMDeploymentController) flushDeployment(ns string, spec aemv1beta1.CacheFlushSpec) aemv1beta1.CacheFlushStatus {
+	status := aemv1beta1.CacheFlushStatus{Phase: aemv1beta1.CacheFlushPhaseFailed}
+	err := validateCacheFlush(spec)
+	if err != nil {
+		status.Reason = err.Error()
+		return status
+	}
+	_, exists, err := ac.getDeployment(ns + "/" + spec.Deployment)
+	if err != nil || !exists {
+		status.Reason = fmt.Sprintf("deployment %s not found", spec.Deployment)
+		return status
+	}
+	podList, err := ac.listPods(ns, labels.SelectorFromSet(LabelsForDeployment(spec.Deployment)))
+	if err != nil {
+		status.Reason = err.Error()
+		return status
+	}
+	pods := GetPods(podList, filterPods("dispatcher"))
+	if len(pods) == 0 {
+		status.Reason = "the deployment has no dispatchers"
+		return status
+	}
+	sort.Sort(ascendingOrdinal(pods))
+	status.Dispatchers = flushDispatchers(pods, spec)
+	status.Phase = aemv1beta1.CacheFlushPhaseCompleted
+	for _, result := range status.Dispatchers {
+		if !result.Succeeded {
+			status.Phase = aemv1beta1.CacheFlushPhaseFailed
+		}
+	}
+	status.CompletionTime = time.Now().UTC().Format(time.RFC3339)
+	return status
+}
+
+// flushDispatchers sends the flush requests to the dispatchers in parallel, the results are
+// returned in the order of the pods.
+func flushDispatchers(pods []*v1.Pod, spec aemv1beta1.CacheFlushSpec) []aemv1beta1.DispatcherFlushStatus {
+	results := make([]aemv1beta1.DispatcherFlushStatus, len(pods))
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		wg.Add(1)
+		go func(i int, pod *v1.Pod) {
+			defer wg.Done()
+			results[i] = flushDispatcher(pod, spec)
+		}(i, pod)
+	}
+	wg.Wait()
+	return results
+}
+
+func flushDispatcher(pod *v1.Pod, spec aemv1beta1.CacheFlushSpec) aemv1beta1.DispatcherFlushStatus {
+	result := aemv1beta1.DispatcherFlushStatus{Name: pod.Name}
+	if !isRunningAndReady(pod) || pod.Status.PodIP == "" {
+		result.Error = "the dispatcher is not ready"
+		return result
+	}
+	baseURL := fmt.Sprintf("http://%s:%s", pod.Status.PodIP, dispatcherFlushPort)
+	var err error
+	if spec.Purge {
+		err = flushClient.Purge(baseURL)
+	} else {
+		err = flushClient.Invalidate(baseURL, spec.Paths)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Succeeded = true
+	return result
+}
+
+// validateCacheFlush checks that the flush has a deployment and absolute content paths.
+func validateCacheFlush(spec aemv1beta1.CacheFlushSpec) error {
+	if spec.Deployment == "" {
+		return fmt.Errorf("the flush has no deployment")
+	}
+	if spec.Purge {
+		return nil
+	}
+	if len(spec.Paths) == 0 {
+		return fmt.Errorf("the flush has no paths")
+	}
+	for _, path := range spec.Paths {
+		if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " \t\r\n") {
+			return fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package operator
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/dispatcher"
+	"k8s.io/api/core/v1"
+)
+
+func TestFlushDispatchers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != dispatcher.InvalidatePath || r.Header.Get("CQ-Handle") == "/apps" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	defaultPort := dispatcherFlushPort
+	dispatcherFlushPort = port
+	defer func() { dispatcherFlushPort = defaultPort }()
+
+	ready := func(name, ip string) *v1.Pod {
+		pod := &v1.Pod{}
+		pod.Name = name
+		pod.Status.PodIP = ip
+		pod.Status.Phase = v1.PodRunning
+		pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+		return pod
+	}
+	pending := ready("dev-dispatcher-003", "")
+	pending.Status.Phase = v1.PodPending
+	pods := []*v1.Pod{ready("dev-dispatcher-001", host), ready("dev-dispatcher-002", host), pending}
+
+	results := flushDispatchers(pods, aemv1beta1.CacheFlushSpec{Paths: []string{"/content/site"}})
+	succeeded := []bool{true, true, false}
+	for i, result := range results {
+		if result.Name != pods[i].Name || result.Succeeded != succeeded[i] {
+			t.Errorf("got: %+v exected succeeded: %v", result, succeeded[i])
+		}
+	}
+	results = flushDispatchers(pods[:1], aemv1beta1.CacheFlushSpec{Paths: []string{"/content/site", "/apps"}})
+	if results[0].Succeeded || results[0].Error == "" {
+		t.Errorf("got: %+v exected a failed flush", results[0])
+	}
+}
+
+func TestValidateCacheFlush(t *testing.T) {
+	table := []struct {
+		spec  aemv1beta1.CacheFlushSpec
+		valid bool
+	}{
+		{spec: aemv1beta1.CacheFlushSpec{Deployment: "dev", Paths: []string{"/content/site"}}, valid: true},
+		{spec: aemv1beta1.CacheFlushSpec{Deployment: "dev", Purge: true}, valid: true},
+		{spec: aemv1beta1.CacheFlushSpec{Paths: []string{"/content/site"}}, valid: false},
+		{spec: aemv1beta1.CacheFlushSpec{Deployment: "dev"}, valid: false},
+		{spec: aemv1beta1.CacheFlushSpec{Deployment: "dev", Paths: []string{"content/site"}}, valid: false},
+		{spec: aemv1beta1.CacheFlushSpec{Deployment: "dev", Paths: []string{"/content/site\r\nCQ-Action: Delete"}}, valid: false},
+	}
+	for _, i := range table {
+		err := validateCacheFlush(i.spec)
+		if (err == nil) != i.valid {
+			t.Errorf("got: %v exected valid: %v for %+v", err, i.valid, i.spec)
+		}
+	}
+}
@@ -9,6 +9,19 @@ import (
 
 func (ac *AEMDeploymentController) addInstance(name, runmode string, deployment *aemv1beta1.AEMDeployment) error {
 	pod := k8s.NewPod(name, runmode, deployment)
+	if runmode == k8s.AEMRunmodeDispatcher {
+		hash, err := k8s.DispatcherConfigHash(ac.clientSet, deployment)
+		if err != nil {
+			ac.logger.Error("Error hashing dispatcher configuration", err)
+			return err
+		}
+		pod.Annotations[k8s.DispatcherConfigHashAnnotation] = hash
+		err = k8s.SetDispatcherConfigFiles(ac.clientSet, pod, deployment)
+		if err != nil {
+			ac.logger.Error("Error reading dispatcher configuration", err)
+			return err
+		}
+	}
 	err := k8s.CreateAndWaitPVC(ac.clientSet, name, deployment)
 	if err != nil {
 		ac.logger.Error("Error creating PVC for", err)
@@ -19,7 +32,7 @@ func (ac *AEMDeploymentController) addInstance(name, runmode string, deployment
 		ac.logger.Error("Error creating pod", err)
 		return err
 	}
-	err = k8s.CreateExternalEndpoint(ac.clientSet, name, runmode, deployment)
+	err = k8s.ExposeInstance(ac.clientSet, ac.endpoints, name, runmode, deployment)
 	if err != nil {
 		ac.logger.Error("Error creating external endpoint", err)
 		return err
@@ -37,7 +50,7 @@ func (ac *AEMDeploymentController) removeInstance(pod *v1.Pod, deployment *aemv1
 		}
 	}
 	deleteOptions := &metav1.DeleteOptions{}
-	ac.clientSet.ExtensionsV1beta1().Ingresses(ns).Delete(k8s.MakeIngressName(pod.Name), deleteOptions)
+	ac.endpoints.Delete(ns, k8s.MakeIngressName(pod.Name), k8s.MakeRouteName(pod.Name))
 	ac.clientSet.CoreV1().Services(ns).Delete(k8s.MakeServiceName(pod.Name), deleteOptions)
 	ac.clientSet.CoreV1().PersistentVolumeClaims(ns).Delete(k8s.MakePVCName(pod.Name), deleteOptions)
 	ac.clientSet.CoreV1().Pods(ns).Delete(pod.Name, deleteOptions)
@@ -11,6 +11,8 @@ import (
 	"go.uber.org/zap/zapcore"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
 	fakeclientset "k8s.io/client-go/kubernetes/fake"
 )
@@ -71,5 +73,8 @@ func getAEMDeploymentController(kubecli kubernetes.Interface) *AEMDeploymentCont
 	return &AEMDeploymentController{
 		logger:    getLogger().Sugar(),
 		clientSet: kubecli,
+		endpoints: k8s.EndpointReconcilers{
+			Ingresses: k8s.NewIngressReconciler(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), k8s.IngressResource),
+		},
 	}
 }
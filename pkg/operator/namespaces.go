@@ -0,0 +1,177 @@
+package operator
+
+import (
+	"time"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	aeminformers "github.com/xumak-grid/aem-operator/pkg/generated/informers/externalversions/aem/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resyncPeriod = 15 * time.Second
+
+// namespaceInformers are the informers of a namespace watched by the operator, of every namespace
+// when the operator watches v1.NamespaceAll.
+type namespaceInformers struct {
+	deployments cache.SharedIndexInformer
+	pods        cache.SharedIndexInformer
+	flushes     cache.SharedIndexInformer
+	stop        chan struct{}
+}
+
+func (ac *AEMDeploymentController) newNamespaceInformers(ns string) *namespaceInformers {
+	informers := &namespaceInformers{
+		deployments: aeminformers.NewAEMDeploymentInformer(ac.aemcli, ns, resyncPeriod, cache.Indexers{}),
+		pods:        coreinformers.NewPodInformer(ac.clientSet, ns, resyncPeriod, cache.Indexers{}),
+		flushes:     aeminformers.NewAEMCacheFlushInformer(ac.aemcli, ns, resyncPeriod, cache.Indexers{}),
+		stop:        make(chan struct{}),
+	}
+	informers.deployments.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ac.handleAddDeployment,
+		DeleteFunc: ac.handleDeleteDeployment,
+		UpdateFunc: ac.handleUpdateDeployment,
+	})
+	informers.flushes.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ac.enqueueCacheFlush,
+		UpdateFunc: func(old, new interface{}) { ac.enqueueCacheFlush(new) },
+	})
+	return informers
+}
+
+// staticNamespaces returns the namespaces of the configuration, every namespace when neither the
+// namespaces nor a namespace selector are set.
+func (ac *AEMDeploymentController) staticNamespaces() []string {
+	if len(ac.config.Namespaces) == 0 && ac.config.NamespaceSelector == "" {
+		return []string{v1.NamespaceAll}
+	}
+	return ac.config.Namespaces
+}
+
+// watchNamespace starts the informers of a namespace and waits for their caches to sync.
+func (ac *AEMDeploymentController) watchNamespace(ns string) {
+	ac.namespacesMu.Lock()
+	if _, ok := ac.namespaces[ns]; ok {
+		ac.namespacesMu.Unlock()
+		return
+	}
+	informers := ac.newNamespaceInformers(ns)
+	ac.namespaces[ns] = informers
+	ac.namespacesMu.Unlock()
+
+	go informers.deployments.Run(informers.stop)
+	go informers.pods.Run(informers.stop)
+	go informers.flushes.Run(informers.stop)
+	if !cache.WaitForCacheSync(informers.stop, informers.deployments.HasSynced, informers.pods.HasSynced, informers.flushes.HasSynced) {
+		ac.logger.Errorf("time out while waiting for cache sync of namespace %q", ns)
+		return
+	}
+	ac.logger.Infof("cache synced for namespace %q", ns)
+}
+
+// unwatchNamespace stops the informers of a namespace, the resources of its deployments are kept.
+func (ac *AEMDeploymentController) unwatchNamespace(ns string) {
+	ac.namespacesMu.Lock()
+	defer ac.namespacesMu.Unlock()
+	informers, ok := ac.namespaces[ns]
+	if !ok {
+		return
+	}
+	close(informers.stop)
+	delete(ac.namespaces, ns)
+	ac.logger.Infof("Stopped watching namespace %q", ns)
+}
+
+// unwatchNamespaces stops the informers of every namespace
+func (ac *AEMDeploymentController) unwatchNamespaces() {
+	ac.namespacesMu.RLock()
+	namespaces := make([]string, 0, len(ac.namespaces))
+	for ns := range ac.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	ac.namespacesMu.RUnlock()
+	for _, ns := range namespaces {
+		ac.unwatchNamespace(ns)
+	}
+}
+
+// informersFor returns the informers watching a namespace, nil when it is not watched
+func (ac *AEMDeploymentController) informersFor(ns string) *namespaceInformers {
+	ac.namespacesMu.RLock()
+	defer ac.namespacesMu.RUnlock()
+	if informers, ok := ac.namespaces[ns]; ok {
+		return informers
+	}
+	return ac.namespaces[v1.NamespaceAll]
+}
+
+// getDeployment returns the deployment of a namespace/name key from the cache of its namespace
+func (ac *AEMDeploymentController) getDeployment(key string) (*aemv1beta1.AEMDeployment, bool, error) {
+	obj, exists, err := ac.getByKey(key, func(i *namespaceInformers) cache.SharedIndexInformer { return i.deployments })
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	return obj.(*aemv1beta1.AEMDeployment), true, nil
+}
+
+// getCacheFlush returns the cache flush of a namespace/name key from the cache of its namespace
+func (ac *AEMDeploymentController) getCacheFlush(key string) (*aemv1beta1.AEMCacheFlush, bool, error) {
+	obj, exists, err := ac.getByKey(key, func(i *namespaceInformers) cache.SharedIndexInformer { return i.flushes })
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	return obj.(*aemv1beta1.AEMCacheFlush), true, nil
+}
+
+func (ac *AEMDeploymentController) getByKey(key string, informer func(*namespaceInformers) cache.SharedIndexInformer) (interface{}, bool, error) {
+	ns, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	informers := ac.informersFor(ns)
+	if informers == nil {
+		return nil, false, nil
+	}
+	return informer(informers).GetIndexer().GetByKey(key)
+}
+
+// listPods returns the pods of a namespace matching the selector from the cache of the namespace
+func (ac *AEMDeploymentController) listPods(ns string, selector labels.Selector) ([]*v1.Pod, error) {
+	informers := ac.informersFor(ns)
+	if informers == nil {
+		return nil, nil
+	}
+	return corelisters.NewPodLister(informers.pods.GetIndexer()).Pods(ns).List(selector)
+}
+
+// newNamespaceInformer watches the namespaces matching the namespace selector of the
+// configuration. The informers of a namespace are started when it gets the labels of the selector
+// and stopped when it loses them or is deleted, the watch reports both as deletions.
+func (ac *AEMDeploymentController) newNamespaceInformer() cache.SharedIndexInformer {
+	selector := ac.config.NamespaceSelector
+	informer := coreinformers.NewFilteredNamespaceInformer(ac.clientSet, resyncPeriod, cache.Indexers{}, func(options *metav1.ListOptions) {
+		options.LabelSelector = selector
+	})
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			namespace, ok := obj.(*v1.Namespace)
+			if ok {
+				go ac.watchNamespace(namespace.Name)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			namespace, ok := obj.(*v1.Namespace)
+			if ok && !isInSlice(namespace.Name, ac.config.Namespaces) {
+				ac.unwatchNamespace(namespace.Name)
+			}
+		},
+	})
+	return informer
+}
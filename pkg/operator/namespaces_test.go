@@ -0,0 +1,67 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/config"
+	aemfake "github.com/xumak-grid/aem-operator/pkg/generated/clientset/versioned/fake"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestStaticNamespaces(t *testing.T) {
+	table := []struct {
+		namespaces []string
+		selector   string
+		output     []string
+	}{
+		{output: []string{v1.NamespaceAll}},
+		{namespaces: []string{"aem", "site"}, output: []string{"aem", "site"}},
+		{selector: "tenant=acme", output: nil},
+	}
+	for _, i := range table {
+		ac := &AEMDeploymentController{config: &config.Config{Namespaces: i.namespaces, NamespaceSelector: i.selector}}
+		got := ac.staticNamespaces()
+		if !reflect.DeepEqual(got, i.output) {
+			t.Errorf("got: %v exected: %v", got, i.output)
+		}
+	}
+}
+
+func TestWatchNamespace(t *testing.T) {
+	deployment := func(ns string) *aemv1beta1.AEMDeployment {
+		return &aemv1beta1.AEMDeployment{ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: ns}}
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dev-author-001", Namespace: "aem", Labels: LabelsForDeployment("dev")}}
+	ac := getAEMDeploymentController(fake.NewSimpleClientset(pod))
+	ac.aemcli = aemfake.NewSimpleClientset(deployment("aem"), deployment("site"))
+	ac.namespaces = map[string]*namespaceInformers{}
+	ac.queue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "aemdeployment")
+	defer ac.queue.ShutDown()
+	defer ac.unwatchNamespaces()
+
+	ac.watchNamespace("aem")
+	_, exists, err := ac.getDeployment("aem/dev")
+	if err != nil || !exists {
+		t.Errorf("deployment of a watched namespace not found: %v", err)
+	}
+	_, exists, _ = ac.getDeployment("site/dev")
+	if exists {
+		t.Error("deployment of a namespace not watched found")
+	}
+	pods, _ := ac.listPods("aem", labels.SelectorFromSet(LabelsForDeployment("dev")))
+	if len(pods) != 1 {
+		t.Errorf("got %d pods", len(pods))
+	}
+
+	ac.unwatchNamespace("aem")
+	_, exists, _ = ac.getDeployment("aem/dev")
+	if exists {
+		t.Error("deployment found after unwatching its namespace")
+	}
+}
@@ -3,7 +3,6 @@ package operator
 import (
 	"fmt"
 	"sort"
-	"strings"
 	"time"
 
 	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
@@ -12,11 +11,14 @@ import (
 
 	"github.com/xumak-grid/go-grid/pkg/pgen"
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
 	podInitializedAnnotation = "initialized"
+	// sourceAgentsRemovedAnnotation marks the copied authors whose source replication agents were deleted
+	sourceAgentsRemovedAnnotation = "source-agents-removed"
 )
 
 var passwordGenerator = pgen.NewGenerator()
@@ -35,7 +37,7 @@ func (ac *AEMDeploymentController) sync(key string) error {
 		ac.logger.Infof("Finished syncing statefulset %s (%s)", key, time.Now().Sub(startTime))
 	}()
 
-	obj, exists, err := ac.aemInformer.GetIndexer().GetByKey(key)
+	obj, exists, err := ac.getDeployment(key)
 	if err != nil {
 		return err
 	}
@@ -45,36 +47,63 @@ func (ac *AEMDeploymentController) sync(key string) error {
 		return nil
 	}
 
-	deployment := (obj.(*aemv1beta1.AEMDeployment)).DeepCopy()
+	deployment := obj.DeepCopy()
 
-	if deployment.Status.Phase == aemv1beta1.DeploymentPhaseNone {
-
-		// creating Initial services
-		err := k8s.CreateServices(ac.clientSet, deployment)
-		if err != nil {
+	// An invalid specification is reported in the status until it is fixed
+	err = k8s.ValidateDeployment(deployment)
+	if err == nil {
+		// the user provided dispatcher configuration is checked before it is rolled out
+		err = k8s.ValidateDispatcherConfigFrom(ac.clientSet, deployment)
+	}
+	if err != nil {
+		ac.logger.Errorf("Invalid deployment %s: %v", key, err)
+		if deployment.Status.SetCondition(aemv1beta1.DeploymentConditionInvalidSpec, err.Error()) {
+			_, err = ac.aemcli.AemV1beta1().AEMDeployments(deployment.Namespace).Update(deployment)
 			return err
 		}
-		ac.logger.Info("Initial services successfully created")
-
-		// creating initial confiMaps
-		err = k8s.SetUpConfigMaps(ac.clientSet, deployment)
+		return nil
+	}
+	if deployment.Status.RemoveCondition(aemv1beta1.DeploymentConditionInvalidSpec) {
+		deployment, err = ac.aemcli.AemV1beta1().AEMDeployments(deployment.Namespace).Update(deployment)
 		if err != nil {
 			return err
 		}
-		ac.logger.Info("Initial configMaps successfully created")
+	}
+
+	if deployment.Status.Phase == aemv1beta1.DeploymentPhaseNone {
+
+		// the source deployment must exist before copying its volumes,
+		// backups are kept after the source deployment is deleted
+		if source := deployment.Spec.Source; source != nil && source.BackupID == "" {
+			_, err := ac.aemcli.AemV1beta1().AEMDeployments(deployment.Namespace).Get(source.Deployment, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("error getting source deployment %s: %v", source.Deployment, err)
+			}
+		}
 
 		deployment.Status.Phase = aemv1beta1.DeploymentPhaseCreating
-		_, err = ac.aemcli.AemV1beta1().AEMDeployments(deployment.Namespace).Update(deployment)
+		_, err := ac.aemcli.AemV1beta1().AEMDeployments(deployment.Namespace).Update(deployment)
 		if err != nil {
 			ac.logger.Error("Error updating status", err)
 		}
 	}
 
+	// the services and configMaps are kept up to date, the farm renders every publisher
+	err = k8s.CreateServices(ac.clientSet, deployment)
+	if err != nil {
+		return err
+	}
+	err = k8s.SetUpConfigMaps(ac.clientSet, deployment)
+	if err != nil {
+		return err
+	}
+	err = k8s.SetUpDispatcherCertificate(ac.dynamicClient, deployment)
+	if err != nil {
+		return err
+	}
+
 	name := deployment.Name
-	podList, _ := ac.podInformer.
-		Lister().
-		Pods(deployment.Namespace).
-		List(labels.SelectorFromSet(LabelsForDeployment(name)))
+	podList, _ := ac.listPods(deployment.Namespace, labels.SelectorFromSet(LabelsForDeployment(name)))
 
 	authorPods := GetPods(podList, filterPods("author"))
 
@@ -104,6 +133,26 @@ func (ac *AEMDeploymentController) sync(key string) error {
 		updateStatus = len(dispatcherPods) > 0
 	}
 
+	// the endpoints are reconciled once the instances match the spec so removed instances are not exposed again
+	if len(authorPods) == authors && len(publishPods) == publishers && len(dispatcherPods) == dispatchers {
+		err = ac.reconcileEndpoints(GetPods(podList, filterPods("author", "publish", "dispatcher")), deployment)
+		if err != nil {
+			ac.logger.Error("Error reconciling external endpoints", err)
+			return err
+		}
+	}
+
+	err = k8s.SyncPodDisruptionBudgets(ac.clientSet, deployment)
+	if err != nil {
+		ac.logger.Error("Error syncing disruption budgets", err)
+		return err
+	}
+	err = k8s.SyncNetworkPolicies(ac.clientSet, deployment)
+	if err != nil {
+		ac.logger.Error("Error syncing network policies", err)
+		return err
+	}
+
 	if updateStatus {
 		// Consider to add this as a deployment condition insteaad of a phase.
 		deployment.Status.Phase = aemv1beta1.DeploymentPhaseResizing
@@ -114,6 +163,15 @@ func (ac *AEMDeploymentController) sync(key string) error {
 		}
 	}
 
+	// copied authors start with the replication agents of their source, they are deleted as soon as
+	// the authors answer so the copies do not replicate to the source publishers
+	for _, pod := range authorPods {
+		err := ac.removeSourceAgents(pod.DeepCopy(), deployment)
+		if err != nil {
+			ac.logger.Infof("Replication agents of the source not removed from %s yet: %v", pod.Name, err)
+		}
+	}
+
 	allPods := GetPods(podList, filterPods("author", "publish", "dispatcher"))
 	for _, pod := range allPods {
 		if !isHealthy(pod) {
@@ -129,6 +187,14 @@ func (ac *AEMDeploymentController) sync(key string) error {
 			return err
 		}
 	}
+	// Restart the dispatchers one at a time when their configuration changed
+	if len(dispatcherPods) == dispatchers {
+		err = ac.rollDispatchers(dispatcherPods, deployment)
+		if err != nil {
+			ac.logger.Error("Error rolling dispatchers", err)
+			return err
+		}
+	}
 	// Check pod initialization
 	for _, pod := range allPods {
 		if !isAuthor(pod) && !isPublish(pod) {
@@ -147,17 +213,50 @@ func (ac *AEMDeploymentController) sync(key string) error {
 				ac.logger.Error("Error checking author config", err)
 				return err
 			}
-			continue
-		}
-		if isPublish(pod) {
-			ac.checkPublishConfig(publishPods, deployment)
 		}
 	}
+	// every publisher flushes every dispatcher
+	err = ac.checkPublishConfig(publishPods, dispatcherPods, deployment)
+	if err != nil {
+		ac.logger.Error("Error checking publish config", err)
+	}
+	// Check backups
+	err = ac.checkBackups(GetPods(podList, filterPods("author", "publish")), deployment)
+	if err != nil {
+		ac.logger.Error("Error checking backups", err)
+		return err
+	}
 	return nil
 }
 
+// reconcileEndpoints keeps the service and the ingress of every instance up to date with the
+// exposure settings, keeps the site in front of the dispatchers and deletes the ingresses of the
+// instances that are no longer public.
+func (ac *AEMDeploymentController) reconcileEndpoints(pods []*v1.Pod, deployment *aemv1beta1.AEMDeployment) error {
+	names := []string{}
+	for _, pod := range pods {
+		runmode := pod.Labels["runmode"]
+		err := k8s.ExposeInstance(ac.clientSet, ac.endpoints, pod.Name, runmode, deployment)
+		if err != nil {
+			return err
+		}
+		if k8s.InstanceExposurePolicy(runmode, deployment) == aemv1beta1.ExposurePublic {
+			names = append(names, pod.Name)
+		}
+	}
+	err := k8s.ExposeSite(ac.clientSet, ac.endpoints, deployment)
+	if err != nil {
+		return err
+	}
+	return k8s.PruneExternalEndpoints(ac.endpoints, names, deployment)
+}
+
 func (ac *AEMDeploymentController) getPodPassword(pod *v1.Pod, deployment string) (string, error) {
-	podSecretsKey := getPodSecretKey(pod.Namespace, deployment, pod.Name)
+	return ac.getPassword(getPodSecretKey(pod.Namespace, deployment, pod.Name))
+}
+
+// getPassword returns the admin password stored under the given secret key.
+func (ac *AEMDeploymentController) getPassword(podSecretsKey string) (string, error) {
 	podSecrets, err := ac.secrets.Get(podSecretsKey)
 	if err != nil {
 		return "", err
@@ -199,14 +298,40 @@ func parseAgentsList(repAgents []map[string]interface{}, repType string) map[str
 	return result
 }
 
-func (ac *AEMDeploymentController) checkPublishConfig(publishPods []*v1.Pod, deployment *aemv1beta1.AEMDeployment) error {
+func (ac *AEMDeploymentController) checkPublishConfig(publishPods, dispatcherPods []*v1.Pod, deployment *aemv1beta1.AEMDeployment) error {
+	dispatcherNames := []string{}
+	for _, d := range dispatcherPods {
+		dispatcherNames = append(dispatcherNames, d.Name)
+	}
 	for _, p := range publishPods {
-		c := aemconfig.Client{}
 		pwd, _ := ac.getPodPassword(p, deployment.Name)
-		dispatcherName := strings.Replace(p.Name, "-publish-", "-dispatcher-", -1)
-		agent := aemconfig.NewAgentPublish(dispatcherName, aemconfig.PolicyCreate)
+		// removes the agents of scaled away dispatchers and, in copied publishers, of the source dispatchers
+		err := ac.cleanupPublishAgents(p, pwd, dispatcherNames)
+		if err != nil {
+			return err
+		}
+		c := aemconfig.Client{}
+		for _, agent := range publishFlushAgents(p, dispatcherPods) {
+			c.RegisterAgent(agent)
+		}
+		// TODO: validate output
+		_, err = c.Do(p.Status.PodIP, "4503", "admin", pwd)
+		if err != nil {
+			ac.logger.Errorf("Error registering dispatchers in %s: %v", p.Name, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// publishFlushAgents returns the flush agents of a publisher, one for every dispatcher as each
+// dispatcher renders all the publishers and may cache the pages of any of them.
+func publishFlushAgents(publish *v1.Pod, dispatcherPods []*v1.Pod) []*aemconfig.Agent {
+	agents := []*aemconfig.Agent{}
+	for _, d := range dispatcherPods {
+		agent := aemconfig.NewAgentPublish(d.Name, aemconfig.PolicyCreate)
 		agent.With = map[string]interface{}{
-			"jcr:title": dispatcherName,
+			"jcr:title": d.Name,
 			"grid":      true,
 			"enabled":   true,
 			"protocolHTTPHeaders": []string{
@@ -221,15 +346,40 @@ func (ac *AEMDeploymentController) checkPublishConfig(publishPods []*v1.Pod, dep
 			"triggerSpecific":    "true",
 			"noVersioning":       "true",
 			"logLevel":           "error",
-			"transportUri":       fmt.Sprintf("http://%s.%s.%s:80/dispatcher/invalidate.cache", dispatcherName, p.Spec.Subdomain, p.Namespace),
+			"transportUri":       fmt.Sprintf("http://%s.%s.%s:80/dispatcher/invalidate.cache", d.Name, publish.Spec.Subdomain, publish.Namespace),
 		}
-		c.RegisterAgent(agent)
-		// TODO: validate output
-		_, err := c.Do(p.Status.PodIP, "4503", "admin", pwd)
+		agents = append(agents, agent)
+	}
+	return agents
+}
+
+// cleanupPublishAgents removes the flush agents created by the operator in a
+// publish instance that do not point to one of the desired dispatchers.
+func (ac *AEMDeploymentController) cleanupPublishAgents(pod *v1.Pod, pwd string, desiredAgents []string) error {
+	listClient := aemconfig.Client{}
+	listClient.RegisterAgent(aemconfig.NewAgentPublish("", aemconfig.PolicyShow))
+	out, err := listClient.Do(pod.Status.PodIP, "4503", "admin", pwd)
+	if err != nil {
+		return err
+	}
+	existingAgents := parseAgentsList(out.Data.Agents, "agents.publish")
+	dc := aemconfig.Client{}
+	for agentName, agentMap := range existingAgents {
+		agentValues, ok := agentMap.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		isGrid, _ := agentValues["grid"].(bool)
+		if isGrid && !isInSlice(agentName, desiredAgents) {
+			dc.RegisterAgent(aemconfig.NewAgentPublish(agentName, aemconfig.PolicyDelete))
+		}
+	}
+	if len(dc.Agents) > 0 {
+		_, err = dc.Do(pod.Status.PodIP, "4503", "admin", pwd)
 		if err != nil {
-			fmt.Println("Error registering dispatcher", err)
 			return err
 		}
+		ac.logger.Infof("Deleting [%v] agent(s) in publish %s", len(dc.Agents), pod.Name)
 	}
 	return nil
 }
@@ -338,11 +488,13 @@ func (ac *AEMDeploymentController) checkPodInitialized(pod *v1.Pod, deployment *
 		repDel := aemconfig.NewAgentAuthor("publish", aemconfig.PolicyDelete)
 		c.RegisterAgent(repDel)
 		c.RegisterUser(user)
-		port := "4502"
-		if isPublish(pod) {
-			port = "4503"
+		port := getInstancePort(pod)
+		currentPwd, err := ac.initialPassword(pod, deployment)
+		if err != nil {
+			ac.logger.Errorf("Error getting the source password of %s: %v", pod.Name, err)
+			return err
 		}
-		_, err := c.Do(pod.Status.PodIP, port, "admin", "admin")
+		_, err = c.Do(pod.Status.PodIP, port, "admin", currentPwd)
 		if err != nil {
 			fmt.Println("error setting password with default password", err)
 		}
@@ -364,6 +516,80 @@ func (ac *AEMDeploymentController) checkPodInitialized(pod *v1.Pod, deployment *
 	return nil
 }
 
+// initialPassword returns the admin password of an instance before it is initialized, instances
+// copied from another deployment keep the password of the source instance.
+func (ac *AEMDeploymentController) initialPassword(pod *v1.Pod, deployment *aemv1beta1.AEMDeployment) (string, error) {
+	source, ok := pod.Annotations[k8s.SourceInstanceAnnotation]
+	if !ok || deployment.Spec.Source == nil {
+		return "admin", nil
+	}
+	sourceSpec := deployment.Spec.Source
+	sourceKey := getPodSecretKey(pod.Namespace, sourceSpec.Deployment, source)
+	if sourceSpec.BackupID != "" {
+		sourceKey = getBackupSecretKey(pod.Namespace, sourceSpec.Deployment, sourceSpec.BackupID, source)
+	}
+	sourcePwd, err := ac.getPassword(sourceKey)
+	if err != nil || sourcePwd == "" {
+		return "admin", err
+	}
+	return sourcePwd, nil
+}
+
+// removeSourceAgents deletes the replication agents created by the operator in the source of a
+// copied author, they replicate to the publishers of the source deployment. It runs once, before
+// the author is initialized and gets the agents of the publishers of its own deployment.
+func (ac *AEMDeploymentController) removeSourceAgents(pod *v1.Pod, deployment *aemv1beta1.AEMDeployment) error {
+	if _, ok := pod.Annotations[k8s.SourceInstanceAnnotation]; !ok || deployment.Spec.Source == nil {
+		return nil
+	}
+	if pod.Annotations[sourceAgentsRemovedAnnotation] == "true" || pod.Annotations[podInitializedAnnotation] == "true" {
+		return nil
+	}
+	if pod.Status.PodIP == "" {
+		return fmt.Errorf("pod has no IP")
+	}
+	pwd, err := ac.initialPassword(pod, deployment)
+	if err != nil {
+		return err
+	}
+	listClient := aemconfig.Client{}
+	listClient.RegisterAgent(aemconfig.NewAgentAuthor("", aemconfig.PolicyShow))
+	out, err := listClient.Do(pod.Status.PodIP, "4502", "admin", pwd)
+	if err != nil {
+		return err
+	}
+	dc := aemconfig.Client{}
+	for _, name := range gridAgents(parseAgentsList(out.Data.Agents, "agents.author")) {
+		dc.RegisterAgent(aemconfig.NewAgentAuthor(name, aemconfig.PolicyDelete))
+	}
+	if len(dc.Agents) > 0 {
+		_, err = dc.Do(pod.Status.PodIP, "4502", "admin", pwd)
+		if err != nil {
+			return err
+		}
+		ac.logger.Infof("Deleted [%v] agent(s) of the source in author %s", len(dc.Agents), pod.Name)
+	}
+	pod.Annotations[sourceAgentsRemovedAnnotation] = "true"
+	_, err = ac.clientSet.CoreV1().Pods(pod.Namespace).Update(pod)
+	return err
+}
+
+// gridAgents returns the sorted names of the agents created by the operator, marked with grid=true
+func gridAgents(agents map[string]interface{}) []string {
+	names := []string{}
+	for name, agent := range agents {
+		values, ok := agent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if isGrid, _ := values["grid"].(bool); isGrid {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (ac *AEMDeploymentController) resizeDeployment(pods []*v1.Pod, desired int, deployment *aemv1beta1.AEMDeployment, runmode string) {
 	actual := len(pods)
 	// Grow action
@@ -0,0 +1,51 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	aemv1beta1 "github.com/xumak-grid/aem-operator/pkg/apis/aem/v1beta1"
+	"github.com/xumak-grid/aem-operator/pkg/k8s"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGridAgents(t *testing.T) {
+	agents := parseAgentsList([]map[string]interface{}{{
+		"agents.author": []interface{}{
+			map[string]interface{}{"name": "stage-publish-002", "grid": true},
+			map[string]interface{}{"name": "publish", "enabled": true},
+			map[string]interface{}{"name": "stage-publish-001", "grid": true},
+		},
+	}}, "agents.author")
+	names := gridAgents(agents)
+	expected := []string{"stage-publish-001", "stage-publish-002"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("got: %v exected: %v", names, expected)
+	}
+}
+
+func TestRemoveSourceAgents(t *testing.T) {
+	deployment := &aemv1beta1.AEMDeployment{}
+	deployment.Name = "qa"
+	deployment.Spec.Source = &aemv1beta1.SourceSpec{Deployment: "stage"}
+	copied := map[string]string{k8s.SourceInstanceAnnotation: "stage-author-001"}
+	table := []struct {
+		annotations map[string]string
+		ok          bool
+	}{
+		{annotations: map[string]string{}, ok: true},
+		{annotations: map[string]string{k8s.SourceInstanceAnnotation: "stage-author-001", sourceAgentsRemovedAnnotation: "true"}, ok: true},
+		{annotations: map[string]string{k8s.SourceInstanceAnnotation: "stage-author-001", podInitializedAnnotation: "true"}, ok: true},
+		// a copied author without an IP is checked again in the next sync
+		{annotations: copied, ok: false},
+	}
+	ac := getAEMDeploymentController(nil)
+	for _, i := range table {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "qa-author-001", Annotations: i.annotations}}
+		err := ac.removeSourceAgents(pod, deployment)
+		if (err == nil) != i.ok {
+			t.Errorf("got: %v for %v", err, i.annotations)
+		}
+	}
+}
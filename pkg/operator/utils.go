@@ -128,6 +128,14 @@ func isPublish(pod *v1.Pod) bool {
 	return pod.Labels["runmode"] == "publish"
 }
 
+// getInstancePort returns the port where the AEM instance of the pod listens
+func getInstancePort(pod *v1.Pod) string {
+	if isPublish(pod) {
+		return "4503"
+	}
+	return "4502"
+}
+
 // getPodSecretKey returns the string key to be used when save secret for the given pod
 func getPodSecretKey(nsName, deploymentName, podName string) string {
 	return fmt.Sprintf("%s/%s", getSecretBasePath(nsName, deploymentName), podName)
@@ -137,3 +145,13 @@ func getPodSecretKey(nsName, deploymentName, podName string) string {
 func getSecretBasePath(ns, deployment string) string {
 	return fmt.Sprintf("secret/%v/%v", ns, deployment)
 }
+
+// getBackupSecretPath returns the base path to be used when save the secrets of a backup
+func getBackupSecretPath(ns, deployment, backupID string) string {
+	return fmt.Sprintf("%s/backups/%s", getSecretBasePath(ns, deployment), backupID)
+}
+
+// getBackupSecretKey returns the string key to be used when save the secrets of a pod at the time of a backup
+func getBackupSecretKey(ns, deployment, backupID, podName string) string {
+	return fmt.Sprintf("%s/%s", getBackupSecretPath(ns, deployment, backupID), podName)
+}
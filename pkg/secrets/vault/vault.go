@@ -11,14 +11,20 @@ type vaultSecretService struct {
 	client *api.Client
 }
 
-// NewSecretService returns a new secret service implementation.
-func NewSecretService() (secrets.SecretService, error) {
-	// TODO: Get token and address from K8S
-	// For testing VAULT_TOKEN, VAULT_ADDRESS should be in the environment.
-	vaultClient, err := api.NewClient(api.DefaultConfig())
+// NewSecretService returns a new secret service implementation using the vault at address,
+// the address and the token of the environment are used when empty.
+func NewSecretService(address, token string) (secrets.SecretService, error) {
+	config := api.DefaultConfig()
+	if address != "" {
+		config.Address = address
+	}
+	vaultClient, err := api.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
+	if token != "" {
+		vaultClient.SetToken(token)
+	}
 	vaultService := vaultSecretService{client: vaultClient}
 	return &vaultService, nil
 }
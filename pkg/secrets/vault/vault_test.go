@@ -6,7 +6,7 @@ import (
 )
 
 func TestVaultGet(t *testing.T) {
-	ss, err := NewSecretService()
+	ss, err := NewSecretService("", "")
 	if err != nil {
 		t.Fatal("error", err)
 	}
@@ -30,7 +30,7 @@ func TestVaultGet(t *testing.T) {
 }
 
 func TestVaultGetCleanUp(t *testing.T) {
-	ss, err := NewSecretService()
+	ss, err := NewSecretService("", "")
 	if err != nil {
 		t.Fatal("error", err)
 	}